@@ -1,231 +1,564 @@
-package archive
-
-import (
-	"archive/zip"
-	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
-
-	"scottgcooper-cbz-webp-converter/fileops"
-
-	"github.com/chai2010/webp"
-)
-
-// ArchiveType represents the type of archive to create
-type ArchiveType string
-
-const (
-	ZIP  ArchiveType = "zip"
-	RAR  ArchiveType = "rar"
-	Z7   ArchiveType = "7z"
-	CBZ  ArchiveType = "cbz"
-	CBR  ArchiveType = "cbr"
-	CB7Z ArchiveType = "cb7z"
-)
-
-// CreateArchive creates an archive for the given directory
-func CreateArchive(sourceDir, archivePath string, archiveType ArchiveType) error {
-	switch strings.ToLower(string(archiveType)) {
-	case "cbz", "zip":
-		return CreateZipArchive(sourceDir, archivePath)
-	case "cbr", "rar":
-		return CreateRarArchive(sourceDir, archivePath)
-	case "cb7z", "7z":
-		return Create7zArchive(sourceDir, archivePath)
-	default:
-		// Default to ZIP for unknown formats
-		fmt.Printf("Unknown format '%s', defaulting to ZIP\n", archiveType)
-		return CreateZipArchive(sourceDir, archivePath)
-	}
-}
-
-// CreateZipArchive creates a ZIP archive with WebP converted images
-func CreateZipArchive(sourceDir, archivePath string) error {
-	// Create the archive file
-	archiveFile, err := os.Create(archivePath)
-	if err != nil {
-		return err
-	}
-	defer archiveFile.Close()
-
-	// Create zip writer
-	zipWriter := zip.NewWriter(archiveFile)
-	defer zipWriter.Close()
-
-	// Walk through the directory and add files to archive
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories in the archive
-		if info.IsDir() {
-			return nil
-		}
-
-		// Create relative path for archive
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-
-		// Check if file is an image
-		if fileops.IsImageFile(path) {
-			// Convert to WebP and add to archive
-			err = addImageAsWebPToZip(zipWriter, path, relPath)
-			if err != nil {
-				fmt.Printf("Error converting and adding %s: %v\n", path, err)
-				return err
-			}
-		} else {
-			// Add non-image file as-is
-			err = addFileToZip(zipWriter, path, relPath)
-			if err != nil {
-				return err
-			}
-		}
-
-		fmt.Printf("  Added to ZIP: %s\n", relPath)
-		return nil
-	})
-
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("Created ZIP: %s\n", archivePath)
-	return nil
-}
-
-// CreateRarArchive creates a RAR archive using the rar command
-func CreateRarArchive(sourceDir, archivePath string) error {
-	// Check if rar command is available
-	_, err := exec.LookPath("rar")
-	if err != nil {
-		return fmt.Errorf("rar command not found. Please install WinRAR or RAR for Linux/Mac")
-	}
-
-	// First create a temporary ZIP with converted images
-	tempZipPath := archivePath + ".temp.zip"
-	err = CreateZipArchive(sourceDir, tempZipPath)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tempZipPath) // Clean up temp file
-
-	// Convert ZIP to RAR using rar command
-	cmd := exec.Command("rar", "a", "-ep1", archivePath, tempZipPath)
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to create RAR archive: %v", err)
-	}
-
-	fmt.Printf("Created RAR: %s\n", archivePath)
-	return nil
-}
-
-// Create7zArchive creates a 7Z archive using the 7z command
-func Create7zArchive(sourceDir, archivePath string) error {
-	// Check if 7z command is available
-	_, err := exec.LookPath("7z")
-	if err != nil {
-		return fmt.Errorf("7z command not found. Please install p7zip")
-	}
-
-	// First create a temporary ZIP with converted images
-	tempZipPath := archivePath + ".temp.zip"
-	err = CreateZipArchive(sourceDir, tempZipPath)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tempZipPath) // Clean up temp file
-
-	// Convert ZIP to 7Z using 7z command
-	cmd := exec.Command("7z", "a", "-t7z", archivePath, tempZipPath)
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to create 7Z archive: %v", err)
-	}
-
-	fmt.Printf("Created 7Z: %s\n", archivePath)
-	return nil
-}
-
-// addImageAsWebPToZip converts an image to WebP and adds it to the ZIP
-func addImageAsWebPToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
-	// Open the input file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Convert to WebP in memory
-	img, format, err := fileops.DecodeImage(file)
-	if err != nil {
-		return err
-	}
-
-	// Create WebP filename
-	webpPath := strings.TrimSuffix(zipPath, filepath.Ext(zipPath)) + ".webp"
-
-	// Create zip file header for WebP
-	header := &zip.FileHeader{
-		Name:   webpPath,
-		Method: zip.Deflate,
-	}
-
-	// Create writer for this file in the zip
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
-	}
-
-	// Encode as WebP directly to zip
-	err = webp.Encode(writer, img, &webp.Options{Quality: 80})
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("  Converted %s -> %s (%s)\n", filepath.Base(filePath), filepath.Base(webpPath), format)
-	return nil
-}
-
-// addFileToZip adds a non-image file to the ZIP archive
-func addFileToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
-	// Open the file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Get file info
-	info, err := file.Stat()
-	if err != nil {
-		return err
-	}
-
-	// Create zip file header
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return err
-	}
-
-	// Set the name in the zip
-	header.Name = zipPath
-	header.Method = zip.Deflate
-
-	// Create writer for this file in the zip
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
-	}
-
-	// Copy file contents to zip
-	_, err = io.Copy(writer, file)
-	return err
-}
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// ArchiveType represents the type of archive to create
+type ArchiveType string
+
+const (
+	ZIP  ArchiveType = "zip"
+	RAR  ArchiveType = "rar"
+	Z7   ArchiveType = "7z"
+	CBZ  ArchiveType = "cbz"
+	CBR  ArchiveType = "cbr"
+	CB7Z ArchiveType = "cb7z"
+)
+
+// Options controls how archives are written
+type Options struct {
+	Image         fileops.Options  // How images are converted
+	RenumberPages bool             // Name pages 0001.webp, 0002.webp... in reading order
+	ComicInfo     ComicInfoMode    // How ComicInfo.xml is written
+	Name          string           // Book name for ComicInfo.xml, defaults to the source directory name
+	Attach        []string         // Files from outside the source directory, stored first at the archive root
+	Filter        Filter           // Which files are stored
+	Verify        bool             // Check RAR and 7Z pages before packing them, see VerifyArchive
+	Log           io.Writer        // Receives a line per stored file, nil for no output
+	OnFile        func(FileReport) // Called after each source file is stored, if set
+}
+
+// Report describes what was stored in an archive
+type Report struct {
+	Archive string
+	Files   []FileReport
+}
+
+// FileReport describes how one source file was stored
+type FileReport struct {
+	Source  string   // Path of the source file inside the source directory
+	Format  string   // Detected image format, empty for files stored as-is
+	Entries []string // Archive entries written for the file
+}
+
+// WriteMapping writes which archive entries each source file became, one
+// line per entry
+func (r *Report) WriteMapping(w io.Writer) error {
+	for _, file := range r.Files {
+		for _, entry := range file.Entries {
+			if _, err := fmt.Fprintf(w, "%s -> %s\n", file.Source, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateArchive creates an archive for the given directory
+func CreateArchive(sourceDir, archivePath string, archiveType ArchiveType, opts Options) (*Report, error) {
+	switch strings.ToLower(string(archiveType)) {
+	case "cbz", "zip":
+		return CreateZipArchive(sourceDir, archivePath, opts)
+	case "cbr", "rar":
+		return CreateRarArchive(sourceDir, archivePath, opts)
+	case "cb7z", "7z":
+		return Create7zArchive(sourceDir, archivePath, opts)
+	default:
+		// Default to ZIP for unknown formats
+		logf(opts.Log, "Unknown format '%s', defaulting to ZIP\n", archiveType)
+		return CreateZipArchive(sourceDir, archivePath, opts)
+	}
+}
+
+// CreateZipArchive creates a ZIP archive with WebP converted images. With an
+// empty sourceDir only the files in opts.Attach are stored.
+func CreateZipArchive(sourceDir, archivePath string, opts Options) (*Report, error) {
+	var entries []Entry
+	if sourceDir != "" {
+		var err error
+		if entries, err = CollectEntries(sourceDir, opts.Filter); err != nil {
+			return nil, err
+		}
+	}
+	entries = attachEntries(entries, opts)
+
+	// Create the archive file
+	archiveFile, err := os.Create(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archiveFile.Close()
+
+	// Never store the archive in itself, as a followed symlink may lead
+	// back to the directory it is written to
+	archiveInfo, err := archiveFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	entries = slices.DeleteFunc(entries, func(entry Entry) bool {
+		info, err := os.Stat(entry.Path)
+		return err == nil && os.SameFile(info, archiveInfo)
+	})
+
+	// Create zip writer
+	zipWriter := zip.NewWriter(archiveFile)
+	defer zipWriter.Close()
+
+	report := &Report{Archive: archivePath}
+	var numberer *pageNumberer
+	if opts.RenumberPages {
+		numberer = newPageNumberer(entries, opts.Image.SplitSpreads)
+	}
+
+	// An existing ComicInfo.xml is merged into the generated one instead of
+	// being stored as a regular file
+	var info *ComicInfo
+	var existingInfo *Entry
+	if opts.ComicInfo != ComicInfoOff {
+		name := opts.Name
+		if name == "" {
+			name = filepath.Base(sourceDir)
+		}
+		info = newComicInfo(name, opts.Image)
+
+		for i, entry := range entries {
+			if strings.EqualFold(entry.Name, ComicInfoName) {
+				existingInfo = &entry
+				entries = append(entries[:i:i], entries[i+1:]...)
+				break
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		file := FileReport{Source: entry.Name}
+		converted := false
+		if entry.IsImage {
+			// Convert to WebP and add to archive
+			file, err = addImageAsWebPToZip(zipWriter, entry, numberer, info, opts)
+			switch {
+			case err == nil:
+				converted = true
+			case storeUnchanged(err):
+				logf(opts.Log, "  Storing %s unchanged: %v\n", entry.Name, err)
+				file = FileReport{Source: entry.Name}
+			default:
+				logf(opts.Log, "Error converting and adding %s: %v\n", entry.Path, err)
+				return nil, err
+			}
+		}
+		if !converted {
+			// Add non-image file as-is, numbered like the pages if it is an
+			// image that could not be converted
+			name := entry.Name
+			if entry.IsImage && numberer != nil {
+				name = numberer.name(name)
+			}
+			if err := addFileToZip(zipWriter, entry.Path, name); err != nil {
+				return nil, err
+			}
+			file.Entries = []string{name}
+			if entry.IsImage && info != nil {
+				info.addPage(unchangedPage(entry.Path))
+			}
+		}
+
+		logf(opts.Log, "  Added to ZIP: %s\n", entry.Name)
+		report.Files = append(report.Files, file)
+		if opts.OnFile != nil {
+			opts.OnFile(file)
+		}
+	}
+
+	if info != nil {
+		if err := addComicInfoToZip(zipWriter, info, existingInfo, opts); err != nil {
+			return nil, err
+		}
+		if existingInfo != nil {
+			report.Files = append(report.Files, FileReport{Source: existingInfo.Name, Entries: []string{ComicInfoName}})
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	logf(opts.Log, "Created ZIP: %s\n", archivePath)
+	return report, nil
+}
+
+// CreateRarArchive creates a RAR archive using the rar command
+func CreateRarArchive(sourceDir, archivePath string, opts Options) (*Report, error) {
+	report, err := createWithCommand(sourceDir, archivePath, RAR, opts)
+	if err != nil {
+		return nil, err
+	}
+	logf(opts.Log, "Created RAR: %s\n", archivePath)
+	return report, nil
+}
+
+// Create7zArchive creates a 7Z archive using the 7z command
+func Create7zArchive(sourceDir, archivePath string, opts Options) (*Report, error) {
+	report, err := createWithCommand(sourceDir, archivePath, Z7, opts)
+	if err != nil {
+		return nil, err
+	}
+	logf(opts.Log, "Created 7Z: %s\n", archivePath)
+	return report, nil
+}
+
+// archiveTool is an external command that writes an archive format
+type archiveTool struct {
+	command string
+	install string
+	add     func(archivePath string, names []string) []string // Arguments adding names to the archive
+	list    []string                                          // Arguments listing the archive, before its path
+}
+
+var (
+	rarTool = archiveTool{
+		command: "rar",
+		install: "Please install WinRAR or RAR for Linux/Mac",
+		add: func(archivePath string, names []string) []string {
+			return append([]string{"a", "-r", archivePath}, names...)
+		},
+		list: []string{"lb"},
+	}
+	sevenZipTool = archiveTool{
+		command: "7z",
+		install: "Please install p7zip",
+		add: func(archivePath string, names []string) []string {
+			return append([]string{"a", "-t7z", archivePath}, names...)
+		},
+		list: []string{"l", "-slt"},
+	}
+)
+
+// toolFor returns the command writing archives of the given type, if it
+// takes one
+func toolFor(archiveType ArchiveType) (archiveTool, bool) {
+	switch strings.ToLower(string(archiveType)) {
+	case "cbr", "rar":
+		return rarTool, true
+	case "cb7z", "7z":
+		return sevenZipTool, true
+	default:
+		return archiveTool{}, false
+	}
+}
+
+// CheckArchiveTool reports why archives of the given type cannot be created,
+// if the command they need is missing
+func CheckArchiveTool(archiveType ArchiveType) error {
+	if tool, ok := toolFor(archiveType); ok {
+		return tool.available()
+	}
+	return nil
+}
+
+func (t archiveTool) available() error {
+	if _, err := exec.LookPath(t.command); err != nil {
+		return fmt.Errorf("%s command not found. %s", t.command, t.install)
+	}
+	return nil
+}
+
+// createWithCommand converts the pages into a temporary ZIP, unpacks it into
+// a staging directory and packs the staged files with the archive tool. With
+// opts.Verify the ZIP is verified before it is packed, as the pages of the
+// final archive cannot be read here. The archive is written under a
+// temporary name first, so an existing archive is replaced instead of added
+// to.
+func createWithCommand(sourceDir, archivePath string, archiveType ArchiveType, opts Options) (*Report, error) {
+	tool, _ := toolFor(archiveType)
+	if err := tool.available(); err != nil {
+		return nil, err
+	}
+	archivePath, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := os.MkdirTemp("", "cbz-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// First create a temporary ZIP with converted images
+	tempZipPath := filepath.Join(tempDir, "pages.zip")
+	report, err := CreateZipArchive(sourceDir, tempZipPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Verify {
+		if err := verifyZip(report, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	stagingDir := filepath.Join(tempDir, "pages")
+	names, err := unpackZip(tempZipPath, stagingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(archivePath)
+	tempArchive := strings.TrimSuffix(archivePath, ext) + ".temp" + ext
+	os.Remove(tempArchive)
+	cmd := exec.Command(tool.command, tool.add(tempArchive, names)...)
+	cmd.Dir = stagingDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tempArchive)
+		return nil, fmt.Errorf("failed to create %s archive: %v: %s", strings.ToUpper(string(archiveType)), err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Rename(tempArchive, archivePath); err != nil {
+		os.Remove(tempArchive)
+		return nil, err
+	}
+
+	report.Archive = archivePath
+	return report, nil
+}
+
+// unpackZip extracts a ZIP archive into dir, returning the names of the
+// files and directories at its top level
+func unpackZip(zipPath, dir string) ([]string, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, file := range reader.File {
+		if !filepath.IsLocal(file.Name) {
+			return nil, fmt.Errorf("invalid archive entry %s", file.Name)
+		}
+		if top, _, _ := strings.Cut(file.Name, "/"); !seen[top] {
+			seen[top] = true
+			names = append(names, top)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// attachEntries puts the files attached with opts.Attach that the filter
+// allows before entries, in natural order. A file of the source directory
+// with the same name wins.
+func attachEntries(entries []Entry, opts Options) []Entry {
+	if len(opts.Attach) == 0 {
+		return entries
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		names[entry.Name] = true
+	}
+
+	var attached []Entry
+	for _, file := range opts.Attach {
+		entry := Entry{Path: file, Name: filepath.Base(file), IsImage: fileops.IsImageFile(file)}
+		if !opts.Filter.Allows(entry) {
+			continue
+		}
+		if names[entry.Name] {
+			logf(opts.Log, "  Not attaching %s, the directory has its own\n", entry.Name)
+			continue
+		}
+		names[entry.Name] = true
+		attached = append(attached, entry)
+	}
+	sort.SliceStable(attached, func(i, j int) bool {
+		return fileops.NaturalLess(attached[i].Name, attached[j].Name)
+	})
+	return append(attached, entries...)
+}
+
+// storeUnchanged reports whether err means an image was recognized but uses
+// a feature the decoders lack, such as an animated WebP. Such files are
+// stored as they are rather than failing the archive; truncated or corrupt
+// images still fail it.
+func storeUnchanged(err error) bool {
+	var decodeErr *fileops.DecodeError
+	return errors.As(err, &decodeErr) && decodeErr.Format != "" && errors.Is(err, fileops.ErrUnsupportedImage)
+}
+
+// unchangedPage describes an image stored unchanged for ComicInfo.xml, with
+// the size read from its header
+func unchangedPage(path string) fileops.Page {
+	var page fileops.Page
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return page
+	}
+	page.Data = data
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		page.Width, page.Height = config.Width, config.Height
+		page.DoublePage = config.Width > config.Height
+	}
+	return page
+}
+
+// addImageAsWebPToZip converts an image to WebP and adds it to the ZIP. With
+// a numberer the pages are renamed to their page numbers; with info they are
+// recorded for ComicInfo.xml.
+func addImageAsWebPToZip(zipWriter *zip.Writer, entry Entry, numberer *pageNumberer, info *ComicInfo, opts Options) (FileReport, error) {
+	file := FileReport{Source: entry.Name}
+
+	// Open the input file
+	input, err := os.Open(entry.Path)
+	if err != nil {
+		return file, err
+	}
+	defer input.Close()
+
+	// Convert to WebP in memory
+	pages, format, err := fileops.ConvertImage(input, entry.Name, opts.Image)
+	if err != nil {
+		return file, err
+	}
+	file.Format = format
+
+	for _, page := range pages {
+		name := page.Name
+		if numberer != nil {
+			name = numberer.name(name)
+		}
+
+		if err := addDataToZip(zipWriter, name, page.Data); err != nil {
+			return file, err
+		}
+		if info != nil {
+			info.addPage(page)
+		}
+
+		file.Entries = append(file.Entries, name)
+		logf(opts.Log, "  Converted %s -> %s (%s)\n", path.Base(entry.Name), path.Base(name), format)
+	}
+
+	return file, nil
+}
+
+// addComicInfoToZip stores ComicInfo.xml, merging the generated info with
+// the existing file, if any
+func addComicInfoToZip(zipWriter *zip.Writer, info *ComicInfo, existing *Entry, opts Options) error {
+	var data []byte
+	if existing != nil {
+		existingData, err := os.ReadFile(existing.Path)
+		if err != nil {
+			return err
+		}
+
+		if opts.ComicInfo == ComicInfoPreserve {
+			data = existingData
+		} else if parsed, err := parseComicInfo(existingData); err != nil {
+			logf(opts.Log, "  Keeping invalid %s unchanged: %v\n", ComicInfoName, err)
+			data = existingData
+		} else {
+			info.merge(parsed)
+		}
+	}
+
+	if data == nil {
+		var err error
+		if data, err = info.Marshal(); err != nil {
+			return err
+		}
+	}
+
+	if err := addDataToZip(zipWriter, ComicInfoName, data); err != nil {
+		return err
+	}
+	logf(opts.Log, "  Added to ZIP: %s\n", ComicInfoName)
+	return nil
+}
+
+// addDataToZip stores data as a file in the ZIP archive
+func addDataToZip(zipWriter *zip.Writer, name string, data []byte) error {
+	// Create zip file header
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+
+	// Create writer for this file in the zip
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+	return err
+}
+
+// addFileToZip adds a non-image file to the ZIP archive
+func addFileToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
+	// Open the file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Get file info
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Create zip file header
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	// Set the name in the zip
+	header.Name = zipPath
+	header.Method = zip.Deflate
+
+	// Create writer for this file in the zip
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	// Copy file contents to zip
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// logf writes a progress line to w, if there is one
+func logf(w io.Writer, format string, args ...any) {
+	if w != nil {
+		fmt.Fprintf(w, format, args...)
+	}
+}
@@ -0,0 +1,145 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// testImage returns a small picture with some detail
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 5), uint8(y * 3), uint8(x + y), 255})
+		}
+	}
+	return img
+}
+
+// jpegData encodes a test image as JPEG
+func jpegData(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(width, height), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// animatedWebPData encodes a two-frame animated WebP
+func animatedWebPData(t *testing.T, width, height int) []byte {
+	t.Helper()
+	anim := &fileops.Animation{
+		Frames: []image.Image{testImage(width, height), image.NewRGBA(image.Rect(0, 0, width, height))},
+		Delays: []int{100, 100},
+	}
+	data, err := fileops.EncodeAnimatedWebP(anim, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeFiles creates a directory holding the named files
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "Book")
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// readArchive returns the entries of a ZIP archive
+func readArchive(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	entries := make(map[string][]byte)
+	for _, file := range reader.File {
+		data, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = data
+	}
+	return entries
+}
+
+func TestCreateZipArchiveBrokenImages(t *testing.T) {
+	page := jpegData(t, 40, 60)
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"truncated", page[:len(page)/2], fileops.ErrTruncatedImage},
+		{"corrupt", append(append([]byte{}, page[:200]...), bytes.Repeat([]byte{0xAA}, len(page)-200)...), fileops.ErrCorruptImage},
+		{"empty", nil, fileops.ErrTruncatedImage},
+		{"not an image", []byte("just some text"), fileops.ErrUnsupportedImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string][]byte{"01.jpg": page, "02.jpg": tt.data, "03.jpg": page})
+			_, err := CreateZipArchive(dir, dir+".cbz", Options{Image: fileops.DefaultOptions()})
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("CreateZipArchive error %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
+
+func TestCreateZipArchiveUnsupportedImage(t *testing.T) {
+	page := jpegData(t, 40, 60)
+	animated := animatedWebPData(t, 90, 30)
+	dir := writeFiles(t, map[string][]byte{"01.jpg": page, "02.webp": animated, "03.jpg": page})
+
+	report, err := CreateZipArchive(dir, dir+".cbz", Options{Image: fileops.DefaultOptions(), RenumberPages: true})
+	if err != nil {
+		t.Fatalf("CreateZipArchive: %v", err)
+	}
+
+	entries := readArchive(t, dir+".cbz")
+	if !bytes.Equal(entries["0002.webp"], animated) {
+		t.Errorf("animated WebP not stored unchanged as 0002.webp")
+	}
+	if got := report.Files[1]; got.Format != "" || len(got.Entries) != 1 || got.Entries[0] != "0002.webp" {
+		t.Errorf("report for the animated WebP is %+v", got)
+	}
+
+	info, err := parseComicInfo(entries[ComicInfoName])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PageCount != 3 || len(info.Pages) != 3 {
+		t.Fatalf("ComicInfo has %d pages (%d listed), want 3", info.PageCount, len(info.Pages))
+	}
+	for i, p := range info.Pages {
+		if p.Image != i {
+			t.Errorf("page %d has index %d", i, p.Image)
+		}
+	}
+	if p := info.Pages[1]; p.ImageWidth != 90 || p.ImageHeight != 30 || !p.DoublePage || p.ImageSize != len(animated) {
+		t.Errorf("animated page is %+v", p)
+	}
+}
@@ -0,0 +1,152 @@
+package archive
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BookName is the metadata found in a directory name such as
+// "One Piece v05 c042" or "Batman (2023) [EN]"
+type BookName struct {
+	Series   string
+	Volume   int    // Volume number, 0 if there is none
+	Chapter  string // Chapter or issue number, e.g. "42" or "42.5", empty if there is none
+	Year     int    // Publication year, 0 if there is none
+	Language string // ISO 639-1 language code, empty if there is none
+}
+
+var (
+	// bookBrackets matches a (...), [...] or {...} group, which holds tags
+	// like the year, the language or the scan group
+	bookBrackets = regexp.MustCompile(`\s*[(\[{]([^)\]}]*)[)\]}]\s*`)
+
+	// The trailing parts of a name, matched from the end one at a time
+	bookYear    = regexp.MustCompile(`\s*-[\s-]*((?:19|20)\d\d)$`)
+	bookVolume  = regexp.MustCompile(`(?i)(?:^|[\s-]+)(?:v|vol\.?|volume)\s*(\d+)$`)
+	bookChapter = regexp.MustCompile(`(?i)(?:(?:^|[\s-]+)(?:c|ch\.?|chapter)\s*|[\s-]*#\s*)(\d+(?:\.\d+)?)$`)
+	bookNumber  = regexp.MustCompile(`[\s-]+(\d+(?:\.\d+)?)$`)
+
+	// bookTitleYear matches a year after a space, which is part of the
+	// title as in "Spider-Man 2099" or "X-Men 1991"
+	bookTitleYear = regexp.MustCompile(`[^\s-]\s+(?:19|20)\d\d$`)
+)
+
+// bookLanguages maps language tags found in names to ISO 639-1 codes
+var bookLanguages = map[string]string{
+	"en": "en", "eng": "en", "english": "en",
+	"ja": "ja", "jp": "ja", "jpn": "ja", "japanese": "ja", "raw": "ja",
+	"fr": "fr", "fre": "fr", "fra": "fr", "french": "fr",
+	"de": "de", "ger": "de", "deu": "de", "german": "de",
+	"es": "es", "spa": "es", "spanish": "es",
+	"it": "it", "ita": "it", "italian": "it",
+	"pt": "pt", "por": "pt", "portuguese": "pt",
+	"ru": "ru", "rus": "ru", "russian": "ru",
+	"ko": "ko", "kor": "ko", "korean": "ko",
+	"zh": "zh", "chi": "zh", "zho": "zh", "chinese": "zh",
+	"nl": "nl", "dut": "nl", "nld": "nl", "dutch": "nl",
+	"pl": "pl", "pol": "pl", "polish": "pl",
+}
+
+// ParseBookName extracts the series, volume, chapter, year and language from
+// a directory name. Years and languages are read from bracketed tags like
+// "(2023)" or "[EN]", other tags are dropped. At the end of the name a
+// volume ("v05", "Vol. 5"), a chapter ("c042", "Ch.42", "#12"), a year after
+// a dash ("Batman-2023") or a plain number ("Saga 012", read as the chapter)
+// are recognized; the rest is the series. A year after a space belongs to the
+// series ("Spider-Man 2099"), and a name that is only a volume or chapter,
+// like "Vol 5", has no series.
+func ParseBookName(name string) BookName {
+	var book BookName
+
+	name = strings.ReplaceAll(name, "_", " ")
+	name = bookBrackets.ReplaceAllStringFunc(name, func(group string) string {
+		tag := strings.TrimSpace(bookBrackets.FindStringSubmatch(group)[1])
+		if year, ok := parseYear(tag); ok && book.Year == 0 {
+			book.Year = year
+		} else if language, ok := bookLanguages[strings.ToLower(tag)]; ok && book.Language == "" {
+			book.Language = language
+		}
+		return " "
+	})
+	name = strings.TrimSpace(name)
+
+	// Take one trailing part at a time, as long as some series is left
+	var value string
+	cut := func(re *regexp.Regexp, keepSeries bool) bool {
+		var ok bool
+		name, value, ok = cutSuffix(name, re, keepSeries)
+		return ok
+	}
+	for {
+		switch {
+		case book.Chapter == "" && book.Volume == 0 && bookTitleYear.MatchString(name):
+			book.Series = strings.TrimRight(name, " -")
+			return book
+		case book.Year == 0 && book.Chapter == "" && book.Volume == 0 && cut(bookYear, true):
+			book.Year, _ = strconv.Atoi(value)
+		case book.Chapter == "" && book.Volume == 0 && cut(bookChapter, false):
+			book.Chapter = trimNumber(value)
+		case book.Volume == 0 && cut(bookVolume, false):
+			book.Volume, _ = strconv.Atoi(value)
+		case book.Chapter == "" && book.Volume == 0 && cut(bookNumber, true):
+			book.Chapter = trimNumber(value)
+		default:
+			book.Series = strings.TrimRight(name, " -")
+			return book
+		}
+	}
+}
+
+// String describes the parsed name for previews, e.g.
+// "Series: One Piece • Volume: 5 • Chapter: 42"
+func (b BookName) String() string {
+	var parts []string
+	if b.Series != "" {
+		parts = append(parts, fmt.Sprintf("Series: %s", b.Series))
+	}
+	if b.Volume != 0 {
+		parts = append(parts, fmt.Sprintf("Volume: %d", b.Volume))
+	}
+	if b.Chapter != "" {
+		parts = append(parts, fmt.Sprintf("Chapter: %s", b.Chapter))
+	}
+	if b.Year != 0 {
+		parts = append(parts, fmt.Sprintf("Year: %d", b.Year))
+	}
+	if b.Language != "" {
+		parts = append(parts, fmt.Sprintf("Language: %s", b.Language))
+	}
+	return strings.Join(parts, " • ")
+}
+
+// cutSuffix removes the part of name matched by re, returning the rest and
+// the first submatch. With keepSeries nothing is cut if it would leave no
+// series, so a name like "2023" stays the series.
+func cutSuffix(name string, re *regexp.Regexp, keepSeries bool) (string, string, bool) {
+	m := re.FindStringSubmatchIndex(name)
+	if m == nil || (keepSeries && strings.TrimRight(name[:m[0]], " -") == "") {
+		return name, "", false
+	}
+	return name[:m[0]], name[m[2]:m[3]], true
+}
+
+// parseYear reports whether tag is a year between 1900 and 2099
+func parseYear(tag string) (int, bool) {
+	if len(tag) != 4 || (!strings.HasPrefix(tag, "19") && !strings.HasPrefix(tag, "20")) {
+		return 0, false
+	}
+	year, err := strconv.Atoi(tag)
+	return year, err == nil
+}
+
+// trimNumber removes leading zeros from a number, keeping one before the
+// decimal point
+func trimNumber(number string) string {
+	number = strings.TrimLeft(number, "0")
+	if number == "" || strings.HasPrefix(number, ".") {
+		number = "0" + number
+	}
+	return number
+}
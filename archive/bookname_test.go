@@ -0,0 +1,77 @@
+package archive
+
+import "testing"
+
+func TestParseBookName(t *testing.T) {
+	tests := []struct {
+		name string
+		want BookName
+	}{
+		{"Plain Name", BookName{Series: "Plain Name"}},
+		{"One Piece v05 c042", BookName{Series: "One Piece", Volume: 5, Chapter: "42"}},
+		{"Akira v03", BookName{Series: "Akira", Volume: 3}},
+		{"Berserk Vol. 41 (Digital) (1r0n)", BookName{Series: "Berserk", Volume: 41}},
+		{"Berserk Volume 7", BookName{Series: "Berserk", Volume: 7}},
+		{"Naruto - Chapter 700", BookName{Series: "Naruto", Chapter: "700"}},
+		{"Naruto Ch.12", BookName{Series: "Naruto", Chapter: "12"}},
+		{"Saga #12", BookName{Series: "Saga", Chapter: "12"}},
+		{"Saga 012", BookName{Series: "Saga", Chapter: "12"}},
+		{"Series 012.5", BookName{Series: "Series", Chapter: "12.5"}},
+		{"Series c000", BookName{Series: "Series", Chapter: "0"}},
+		{"Series c0.5", BookName{Series: "Series", Chapter: "0.5"}},
+		{"Batman-2023", BookName{Series: "Batman", Year: 2023}},
+		{"Batman - 2023", BookName{Series: "Batman", Year: 2023}},
+		{"Batman (2023) [EN]", BookName{Series: "Batman", Year: 2023, Language: "en"}},
+		{"Saga 012 (2019) [EN]", BookName{Series: "Saga", Chapter: "12", Year: 2019, Language: "en"}},
+		{"Dorohedoro_v01_[Japanese]", BookName{Series: "Dorohedoro", Volume: 1, Language: "ja"}},
+		{"Hunter x Hunter v36 c380 (2018) (French)", BookName{Series: "Hunter x Hunter", Volume: 36, Chapter: "380", Year: 2018, Language: "fr"}},
+		{"Bleach (2001) (2005) [EN] [FR]", BookName{Series: "Bleach", Year: 2001, Language: "en"}},
+		{"Bleach {Scans} (Group)", BookName{Series: "Bleach"}},
+
+		// A year after a space is part of the title
+		{"Spider-Man 2099", BookName{Series: "Spider-Man 2099"}},
+		{"X-Men 1991", BookName{Series: "X-Men 1991"}},
+		{"Series 2027", BookName{Series: "Series 2027"}},
+		{"X-Men 1991 (1991)", BookName{Series: "X-Men 1991", Year: 1991}},
+		{"Spider-Man-2099", BookName{Series: "Spider-Man", Year: 2099}},
+		{"Spider-Man 2099 v1", BookName{Series: "Spider-Man 2099", Volume: 1}},
+		{"Spider-Man 2099 012", BookName{Series: "Spider-Man 2099", Chapter: "12"}},
+
+		// Names that are only a volume or chapter have no series, numbers and
+		// years are kept as the series
+		{"Vol 5", BookName{Volume: 5}},
+		{"v01", BookName{Volume: 1}},
+		{"Chapter 3", BookName{Chapter: "3"}},
+		{"#7", BookName{Chapter: "7"}},
+		{"2023", BookName{Series: "2023"}},
+		{"012", BookName{Series: "012"}},
+		{"(2023)", BookName{Year: 2023}},
+		{"", BookName{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseBookName(tt.name); got != tt.want {
+				t.Errorf("ParseBookName(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookNameString(t *testing.T) {
+	tests := []struct {
+		book BookName
+		want string
+	}{
+		{BookName{Series: "Akira"}, "Series: Akira"},
+		{BookName{Series: "One Piece", Volume: 5, Chapter: "42"}, "Series: One Piece • Volume: 5 • Chapter: 42"},
+		{BookName{Series: "Batman", Year: 2023, Language: "en"}, "Series: Batman • Year: 2023 • Language: en"},
+		{BookName{Volume: 5}, "Volume: 5"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.book.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.book, got, tt.want)
+		}
+	}
+}
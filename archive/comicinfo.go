@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// ComicInfoName is the archive name of the metadata file read by comic
+// servers and readers such as Komga and Kavita
+const ComicInfoName = "ComicInfo.xml"
+
+// ComicInfoMode controls how ComicInfo.xml is written
+type ComicInfoMode int
+
+const (
+	ComicInfoMerge    ComicInfoMode = iota // Generate ComicInfo.xml, keeping the fields of an existing one
+	ComicInfoPreserve                      // Store an existing ComicInfo.xml unchanged, generate one otherwise
+	ComicInfoOff                           // Do not generate ComicInfo.xml
+)
+
+// String returns the name of the ComicInfo mode
+func (c ComicInfoMode) String() string {
+	switch c {
+	case ComicInfoPreserve:
+		return "preserve"
+	case ComicInfoOff:
+		return "off"
+	default:
+		return "merge"
+	}
+}
+
+// ParseComicInfoMode parses a ComicInfo mode name (merge, preserve or off)
+func ParseComicInfoMode(name string) (ComicInfoMode, error) {
+	switch strings.ToLower(name) {
+	case "merge":
+		return ComicInfoMerge, nil
+	case "preserve":
+		return ComicInfoPreserve, nil
+	case "off":
+		return ComicInfoOff, nil
+	default:
+		return ComicInfoMerge, fmt.Errorf("unknown ComicInfo mode '%s' (use merge, preserve or off)", name)
+	}
+}
+
+// ComicInfo is the ComicInfo.xml document (schema v2.0). Fields the
+// converter does not fill in are kept in Other, so merging an existing file
+// loses nothing.
+type ComicInfo struct {
+	XMLName     xml.Name        `xml:"ComicInfo"`
+	XSI         string          `xml:"xmlns:xsi,attr"`
+	XSD         string          `xml:"xmlns:xsd,attr"`
+	Title       string          `xml:"Title,omitempty"`
+	Series      string          `xml:"Series,omitempty"`
+	Number      string          `xml:"Number,omitempty"`
+	Volume      int             `xml:"Volume,omitempty"`
+	Year        int             `xml:"Year,omitempty"`
+	Other       []xmlElement    `xml:",any"`
+	PageCount   int             `xml:"PageCount,omitempty"`
+	LanguageISO string          `xml:"LanguageISO,omitempty"`
+	Manga       string          `xml:"Manga,omitempty"`
+	Pages       []ComicPageInfo `xml:"Pages>Page"`
+}
+
+// ComicPageInfo describes one page of the archive
+type ComicPageInfo struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageSize   int    `xml:"ImageSize,attr,omitempty"`
+	Key         string `xml:"Key,attr,omitempty"`
+	Bookmark    string `xml:"Bookmark,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+}
+
+// xmlElement is an element ComicInfo does not model, kept verbatim
+type xmlElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",innerxml"`
+}
+
+// newComicInfo starts a ComicInfo for an archive made from the directory
+// dirName, filled in from the parsed name. Volumes without a chapter number
+// are numbered by volume.
+func newComicInfo(dirName string, opts fileops.Options) *ComicInfo {
+	book := ParseBookName(strings.TrimSpace(dirName))
+	info := &ComicInfo{
+		Series:      book.Series,
+		Number:      book.Chapter,
+		Volume:      book.Volume,
+		Year:        book.Year,
+		LanguageISO: book.Language,
+	}
+	if info.Number == "" && book.Volume != 0 {
+		info.Number = strconv.Itoa(book.Volume)
+	}
+
+	if opts.RightToLeft {
+		info.Manga = "YesAndRightToLeft"
+	}
+	return info
+}
+
+// addPage records a page stored in the archive. The first page is the cover.
+func (c *ComicInfo) addPage(page fileops.Page) {
+	info := ComicPageInfo{
+		Image:       len(c.Pages),
+		DoublePage:  page.DoublePage,
+		ImageSize:   len(page.Data),
+		ImageWidth:  page.Width,
+		ImageHeight: page.Height,
+	}
+	if info.Image == 0 {
+		info.Type = "FrontCover"
+	}
+	c.Pages = append(c.Pages, info)
+	c.PageCount = len(c.Pages)
+}
+
+// merge fills in the fields of c from an existing ComicInfo.xml. Values from
+// the existing file win, except for the page list, which describes the
+// converted pages. Page types, keys and bookmarks are carried over when the
+// page count is unchanged.
+func (c *ComicInfo) merge(existing *ComicInfo) {
+	if existing.Title != "" {
+		c.Title = existing.Title
+	}
+	if existing.Series != "" {
+		c.Series = existing.Series
+	}
+	if existing.Number != "" {
+		c.Number = existing.Number
+	}
+	if existing.Volume != 0 {
+		c.Volume = existing.Volume
+	}
+	if existing.Year != 0 {
+		c.Year = existing.Year
+	}
+	if existing.LanguageISO != "" {
+		c.LanguageISO = existing.LanguageISO
+	}
+	if existing.Manga != "" {
+		c.Manga = existing.Manga
+	}
+	c.Other = existing.Other
+
+	if len(existing.Pages) == len(c.Pages) {
+		for i, page := range existing.Pages {
+			if page.Type != "" {
+				c.Pages[i].Type = page.Type
+			}
+			c.Pages[i].Key = page.Key
+			c.Pages[i].Bookmark = page.Bookmark
+		}
+	}
+}
+
+// parseComicInfo reads an existing ComicInfo.xml
+func parseComicInfo(data []byte) (*ComicInfo, error) {
+	info := &ComicInfo{}
+	if err := xml.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Marshal encodes the document with the XML header and the schema
+// namespaces readers expect
+func (c *ComicInfo) Marshal() ([]byte, error) {
+	c.XSI = "http://www.w3.org/2001/XMLSchema-instance"
+	c.XSD = "http://www.w3.org/2001/XMLSchema"
+
+	data, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
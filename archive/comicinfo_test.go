@@ -0,0 +1,222 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// existingComicInfo is a ComicInfo.xml as written by other tools
+const existingComicInfo = `<?xml version="1.0"?>
+<ComicInfo>
+  <Title>Romance Dawn</Title>
+  <Series>One Piece (Official)</Series>
+  <Writer>Eiichiro Oda</Writer>
+  <Summary>The <i>first</i> volume</Summary>
+  <Year>1997</Year>
+  <Pages>
+    <Page Image="0" Type="FrontCover" />
+    <Page Image="1" Type="InnerCover" Bookmark="Chapter 1" />
+    <Page Image="2" Key="k2" />
+  </Pages>
+</ComicInfo>`
+
+// comicPages returns n page descriptions of increasing size
+func comicPages(n int) []fileops.Page {
+	var pages []fileops.Page
+	for i := 0; i < n; i++ {
+		pages = append(pages, fileops.Page{Data: make([]byte, 100+i), Width: 40, Height: 60, DoublePage: i == 1})
+	}
+	return pages
+}
+
+func TestComicInfoAddPage(t *testing.T) {
+	info := newComicInfo("Book", fileops.DefaultOptions())
+	for _, page := range comicPages(3) {
+		info.addPage(page)
+	}
+
+	if info.PageCount != 3 {
+		t.Errorf("PageCount %d, want 3", info.PageCount)
+	}
+	want := []ComicPageInfo{
+		{Image: 0, Type: "FrontCover", ImageSize: 100, ImageWidth: 40, ImageHeight: 60},
+		{Image: 1, DoublePage: true, ImageSize: 101, ImageWidth: 40, ImageHeight: 60},
+		{Image: 2, ImageSize: 102, ImageWidth: 40, ImageHeight: 60},
+	}
+	for i := range want {
+		if info.Pages[i] != want[i] {
+			t.Errorf("page %d is %+v, want %+v", i, info.Pages[i], want[i])
+		}
+	}
+}
+
+func TestNewComicInfo(t *testing.T) {
+	tests := []struct {
+		dir         string
+		rightToLeft bool
+		want        ComicInfo
+	}{
+		{"One Piece v05 c042 (2019) [EN]", false, ComicInfo{Series: "One Piece", Number: "42", Volume: 5, Year: 2019, LanguageISO: "en"}},
+		{"Akira v03", false, ComicInfo{Series: "Akira", Number: "3", Volume: 3}},
+		{"Berserk", true, ComicInfo{Series: "Berserk", Manga: "YesAndRightToLeft"}},
+	}
+
+	for _, tt := range tests {
+		opts := fileops.DefaultOptions()
+		opts.RightToLeft = tt.rightToLeft
+		got := newComicInfo(tt.dir, opts)
+		if got.Series != tt.want.Series || got.Number != tt.want.Number || got.Volume != tt.want.Volume ||
+			got.Year != tt.want.Year || got.LanguageISO != tt.want.LanguageISO || got.Manga != tt.want.Manga {
+			t.Errorf("newComicInfo(%q) = %+v, want %+v", tt.dir, *got, tt.want)
+		}
+	}
+}
+
+func TestComicInfoMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		pages    int
+		want     ComicInfo // Fields and page types, keys and bookmarks
+	}{
+		{
+			name:     "existing values win",
+			existing: existingComicInfo,
+			pages:    3,
+			want: ComicInfo{Title: "Romance Dawn", Series: "One Piece (Official)", Number: "1", Volume: 1, Year: 1997, LanguageISO: "en",
+				Pages: []ComicPageInfo{{Type: "FrontCover"}, {Type: "InnerCover", Bookmark: "Chapter 1"}, {Key: "k2"}}},
+		},
+		{
+			name:     "page count changed",
+			existing: existingComicInfo,
+			pages:    4,
+			want: ComicInfo{Title: "Romance Dawn", Series: "One Piece (Official)", Number: "1", Volume: 1, Year: 1997, LanguageISO: "en",
+				Pages: []ComicPageInfo{{Type: "FrontCover"}, {}, {}, {}}},
+		},
+		{
+			name:     "empty",
+			existing: `<ComicInfo></ComicInfo>`,
+			pages:    2,
+			want: ComicInfo{Series: "One Piece", Number: "1", Volume: 1, LanguageISO: "en",
+				Pages: []ComicPageInfo{{Type: "FrontCover"}, {}}},
+		},
+		{
+			name:     "language and reading direction",
+			existing: `<ComicInfo><LanguageISO>ja</LanguageISO><Manga>No</Manga><Number>1.5</Number></ComicInfo>`,
+			pages:    1,
+			want: ComicInfo{Series: "One Piece", Number: "1.5", Volume: 1, LanguageISO: "ja", Manga: "No",
+				Pages: []ComicPageInfo{{Type: "FrontCover"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newComicInfo("One Piece v01 [EN]", fileops.DefaultOptions())
+			for _, page := range comicPages(tt.pages) {
+				info.addPage(page)
+			}
+			existing, err := parseComicInfo([]byte(tt.existing))
+			if err != nil {
+				t.Fatal(err)
+			}
+			info.merge(existing)
+
+			if info.Title != tt.want.Title || info.Series != tt.want.Series || info.Number != tt.want.Number ||
+				info.Volume != tt.want.Volume || info.Year != tt.want.Year || info.LanguageISO != tt.want.LanguageISO || info.Manga != tt.want.Manga {
+				t.Errorf("merged %+v, want %+v", *info, tt.want)
+			}
+			if info.PageCount != tt.pages || len(info.Pages) != tt.pages {
+				t.Fatalf("merged %d pages (PageCount %d), want %d", len(info.Pages), info.PageCount, tt.pages)
+			}
+			for i, page := range info.Pages {
+				want := tt.want.Pages[i]
+				if page.Image != i || page.ImageSize != 100+i || page.Type != want.Type || page.Key != want.Key || page.Bookmark != want.Bookmark {
+					t.Errorf("page %d is %+v, want %+v", i, page, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComicInfoMergeKeepsOtherFields(t *testing.T) {
+	info := newComicInfo("Book", fileops.DefaultOptions())
+	existing, err := parseComicInfo([]byte(existingComicInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.merge(existing)
+
+	data, err := info.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{
+		xml.Header,
+		`<ComicInfo xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">`,
+		"<Writer>Eiichiro Oda</Writer>",
+		"<Summary>The <i>first</i> volume</Summary>",
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("merged ComicInfo.xml has no %q:\n%s", want, data)
+		}
+	}
+
+	reparsed, err := parseComicInfo(data)
+	if err != nil {
+		t.Fatalf("parsing the merged file: %v", err)
+	}
+	if reparsed.Title != "Romance Dawn" || len(reparsed.Other) != 2 {
+		t.Errorf("merged file reads back as %+v", *reparsed)
+	}
+}
+
+func TestCreateZipArchiveComicInfo(t *testing.T) {
+	page := jpegData(t, 40, 60)
+	tests := []struct {
+		name   string
+		mode   ComicInfoMode
+		stored bool // Whether the existing file is stored unchanged
+	}{
+		{"merge", ComicInfoMerge, false},
+		{"preserve", ComicInfoPreserve, true},
+		{"off", ComicInfoOff, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string][]byte{
+				"01.jpg": page, "02.jpg": page, "03.jpg": page,
+				"comicinfo.xml": []byte(existingComicInfo),
+			})
+			_, err := CreateZipArchive(dir, dir+".cbz", Options{Image: fileops.DefaultOptions(), ComicInfo: tt.mode})
+			if err != nil {
+				t.Fatalf("CreateZipArchive: %v", err)
+			}
+
+			entries := readArchive(t, dir+".cbz")
+			data, ok := entries[ComicInfoName]
+			if !ok {
+				data, ok = entries["comicinfo.xml"]
+			}
+			if !ok {
+				t.Fatalf("archive has no ComicInfo.xml: %v", entries)
+			}
+			if stored := string(data) == existingComicInfo; stored != tt.stored {
+				t.Errorf("existing file stored unchanged: %v, want %v", stored, tt.stored)
+			}
+			info, err := parseComicInfo(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Title != "Romance Dawn" || len(info.Pages) != 3 {
+				t.Errorf("ComicInfo.xml has title %q and %d pages", info.Title, len(info.Pages))
+			}
+			if !tt.stored && (info.PageCount != 3 || info.Pages[2].ImageSize == 0 || info.Pages[1].Type != "InnerCover") {
+				t.Errorf("merged pages are %+v", info.Pages)
+			}
+		})
+	}
+}
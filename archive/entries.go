@@ -0,0 +1,189 @@
+package archive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// Entry is a source file to be stored in an archive
+type Entry struct {
+	Path    string // File path on disk
+	Name    string // Path inside the archive, relative to the source directory, with forward slashes
+	IsImage bool   // The file is converted instead of stored as-is
+}
+
+// CollectEntries lists the files below sourceDir that filter allows, in
+// natural order, so that page2.jpg is stored before page10.jpg and readers
+// that keep the archive order show the pages in sequence
+func CollectEntries(sourceDir string, filter Filter) ([]Entry, error) {
+	entries, _, err := collectEntries(sourceDir, filter)
+	return entries, err
+}
+
+// collectEntries lists the files below sourceDir in natural order, split
+// into the ones filter allows and the ones it leaves out
+func collectEntries(sourceDir string, filter Filter) ([]Entry, []Entry, error) {
+	var entries, skipped []Entry
+	err := walkFiles(sourceDir, filter, func(filePath, relPath string) error {
+		entry := Entry{
+			Path:    filePath,
+			Name:    relPath,
+			IsImage: fileops.IsImageFile(filePath),
+		}
+		if filter.Allows(entry) {
+			entries = append(entries, entry)
+		} else {
+			skipped = append(skipped, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, list := range [][]Entry{entries, skipped} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return fileops.NaturalLess(list[i].Name, list[j].Name)
+		})
+	}
+	return entries, skipped, nil
+}
+
+// FindBookDirectories finds the directories below root that hold a book:
+// directories with images directly inside them and no images further down,
+// such as the chapters of Series/Volume/Chapter. The paths are relative to
+// root with forward slashes, in natural order; root itself is "." when it
+// holds the only images. Images the filter leaves out are skipped.
+func FindBookDirectories(root string, filter Filter) ([]string, error) {
+	hasImages := make(map[string]bool)
+	err := walkFiles(root, filter, func(filePath, relPath string) error {
+		if fileops.IsImageFile(filePath) && filter.Allows(Entry{Path: filePath, Name: relPath, IsImage: true}) {
+			hasImages[path.Dir(relPath)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A directory is not a book if a directory below it has images
+	parents := make(map[string]bool)
+	for dir := range hasImages {
+		for dir != "." {
+			dir = path.Dir(dir)
+			parents[dir] = true
+		}
+	}
+
+	var books []string
+	for dir := range hasImages {
+		if !parents[dir] {
+			books = append(books, dir)
+		}
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return fileops.NaturalLess(books[i], books[j])
+	})
+	return books, nil
+}
+
+// pageNumberer renames pages to sequential numbers (0001.webp, 0002.webp...)
+// in the order they are stored. Each directory of the archive is numbered
+// separately, and the numbers are padded to the same width throughout.
+type pageNumberer struct {
+	next  map[string]int
+	width int
+}
+
+// newPageNumberer creates a numberer wide enough for the images in entries,
+// counting two pages per image when spreads may be split
+func newPageNumberer(entries []Entry, splitSpreads bool) *pageNumberer {
+	pages := 0
+	for _, entry := range entries {
+		if entry.IsImage {
+			pages++
+		}
+	}
+	if splitSpreads {
+		pages *= 2
+	}
+
+	return &pageNumberer{
+		next:  make(map[string]int),
+		width: max(4, len(strconv.Itoa(pages))),
+	}
+}
+
+// name returns the numbered archive name for a page, keeping its directory
+// and extension
+func (n *pageNumberer) name(page string) string {
+	dir := path.Dir(page)
+	n.next[dir]++
+	name := fmt.Sprintf("%0*d%s", n.width, n.next[dir], path.Ext(page))
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// LooseFiles lists the files directly inside dir that the filter's hidden
+// file and symlink policy allows, in natural order
+func LooseFiles(dir string, filter Filter) ([]string, error) {
+	infos, err := ReadDir(dir, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return fileops.NaturalLess(files[i], files[j])
+	})
+	return files, nil
+}
+
+// LooseFileMode controls what happens to files lying next to the
+// directories being archived, such as a cover.jpg beside chapter folders
+type LooseFileMode int
+
+const (
+	LooseArchive LooseFileMode = iota // Store the loose files together in an archive of their own
+	LooseAttach                       // Add the file to the archive of each directory
+	LooseIgnore                       // Leave the file out
+)
+
+// String returns the name of the loose file mode
+func (l LooseFileMode) String() string {
+	switch l {
+	case LooseAttach:
+		return "attach"
+	case LooseIgnore:
+		return "ignore"
+	default:
+		return "archive"
+	}
+}
+
+// ParseLooseFileMode parses a loose file mode name (archive, attach or ignore)
+func ParseLooseFileMode(name string) (LooseFileMode, error) {
+	switch strings.ToLower(name) {
+	case "archive":
+		return LooseArchive, nil
+	case "attach":
+		return LooseAttach, nil
+	case "ignore":
+		return LooseIgnore, nil
+	default:
+		return LooseArchive, fmt.Errorf("unknown loose file mode '%s' (use archive, attach or ignore)", name)
+	}
+}
@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultExcludes are the junk files left out of archives unless
+// Filter.KeepJunk is set: thumbnail caches, folder settings, macOS resource
+// forks and release notes or links from download sites
+var DefaultExcludes = []string{
+	"Thumbs.db", "ehthumbs.db", "desktop.ini", ".DS_Store", "._*",
+	"__MACOSX", ".AppleDouble", "*.nfo", "*.url", "*.lnk", "*.sfv",
+}
+
+// Filter selects which files of a source directory are stored. Patterns use
+// path.Match syntax and ignore case. A pattern without a slash matches the
+// file name or the name of any directory above it, so "extras" covers every
+// file in an extras folder; a pattern with a slash matches the whole path
+// inside the archive.
+type Filter struct {
+	Include       []string    // When set, only files matching one of these are stored
+	Exclude       []string    // Files matching one of these are left out
+	ImagesOnly    bool        // Store images only, besides ComicInfo.xml
+	KeepJunk      bool        // Store the files in DefaultExcludes too
+	IncludeHidden bool        // Visit files and directories whose name starts with a dot
+	Symlinks      SymlinkMode // Whether symbolic links are followed
+}
+
+// SymlinkMode controls how directory walks treat symbolic links
+type SymlinkMode int
+
+const (
+	SymlinkFollow SymlinkMode = iota // Follow links to files and directories, skipping loops
+	SymlinkSkip                      // Leave links out
+)
+
+// String returns the name of the symlink mode
+func (s SymlinkMode) String() string {
+	if s == SymlinkSkip {
+		return "skip"
+	}
+	return "follow"
+}
+
+// ParseSymlinkMode parses a symlink mode name (follow or skip)
+func ParseSymlinkMode(name string) (SymlinkMode, error) {
+	switch strings.ToLower(name) {
+	case "follow":
+		return SymlinkFollow, nil
+	case "skip":
+		return SymlinkSkip, nil
+	default:
+		return SymlinkFollow, fmt.Errorf("unknown symlink mode '%s' (use follow or skip)", name)
+	}
+}
+
+// Validate reports the first malformed pattern
+func (f Filter) Validate() error {
+	for _, pattern := range append(f.Include, f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether entry is stored
+func (f Filter) Allows(entry Entry) bool {
+	if f.ImagesOnly && !entry.IsImage && !strings.EqualFold(entry.Name, ComicInfoName) {
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, entry.Name) {
+		return false
+	}
+	if matchAny(f.Exclude, entry.Name) {
+		return false
+	}
+	return f.KeepJunk || !matchAny(DefaultExcludes, entry.Name)
+}
+
+// matchAny reports whether one of the patterns matches name, a slash
+// separated path
+func matchAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+			continue
+		}
+		for _, part := range strings.Split(name, "/") {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"testing"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+func TestFilterAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		entry  string
+		want   bool
+	}{
+		{"no patterns", Filter{}, "01.jpg", true},
+		{"include by name", Filter{Include: []string{"*.jpg"}}, "pages/01.jpg", true},
+		{"include misses", Filter{Include: []string{"*.jpg"}}, "notes.txt", false},
+		{"include ignores case", Filter{Include: []string{"*.JPG"}}, "01.jpg", true},
+		{"include by directory", Filter{Include: []string{"bonus"}}, "Bonus/01.jpg", true},
+		{"exclude by name", Filter{Exclude: []string{"credits*"}}, "extras/credits.png", false},
+		{"exclude by directory", Filter{Exclude: []string{"extras"}}, "extras/sketch/01.jpg", false},
+		{"exclude needs a whole name", Filter{Exclude: []string{"extra"}}, "extras/01.jpg", true},
+		{"exclude wins over include", Filter{Include: []string{"*.jpg"}, Exclude: []string{"00*"}}, "000.jpg", false},
+		{"path pattern", Filter{Exclude: []string{"extras/*.jpg"}}, "extras/01.jpg", false},
+		{"path pattern is anchored", Filter{Exclude: []string{"extras/*.jpg"}}, "volume/extras/01.jpg", true},
+		{"path pattern does not cross slashes", Filter{Exclude: []string{"extras/*"}}, "extras/a/01.jpg", true},
+		{"junk", Filter{}, "Thumbs.db", false},
+		{"junk in a subdirectory", Filter{}, "ch1/.DS_Store", false},
+		{"junk directory", Filter{}, "__MACOSX/01.jpg", false},
+		{"junk pattern", Filter{}, "release.NFO", false},
+		{"resource fork", Filter{}, "._01.jpg", false},
+		{"junk kept", Filter{KeepJunk: true}, "Thumbs.db", true},
+		{"images only", Filter{ImagesOnly: true}, "notes.txt", false},
+		{"images only keeps images", Filter{ImagesOnly: true}, "01.jpg", true},
+		{"images only keeps ComicInfo.xml", Filter{ImagesOnly: true}, "comicinfo.xml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := Entry{Path: tt.entry, Name: tt.entry, IsImage: fileops.IsImageFile(tt.entry)}
+			if got := tt.filter.Allows(entry); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		ok     bool
+	}{
+		{Filter{}, true},
+		{Filter{Include: []string{"*.jpg", "ch[0-9]*"}, Exclude: []string{"extras/*"}}, true},
+		{Filter{Include: []string{"ch[0-9"}}, false},
+		{Filter{Exclude: []string{"*.jpg", "\\"}}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.filter.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tt.filter, err, tt.ok)
+		}
+	}
+}
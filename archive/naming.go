@@ -0,0 +1,286 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultNameTemplate names archives after their source directory
+const DefaultNameTemplate = "{name}.{ext}"
+
+// NameTemplates are the templates offered in the TUI
+var NameTemplates = []string{
+	DefaultNameTemplate,
+	"{series} v{volume:02} c{chapter:03}.{ext}",
+	"{series} {chapter:03}.{ext}",
+	"{series} v{volume:02}.{ext}",
+	"{series} ({year}) v{volume:02} c{chapter:03}.{ext}",
+}
+
+// nameFields are the placeholders a template may use
+var nameFields = []string{"name", "series", "volume", "chapter", "year", "language", "ext"}
+
+// NameTemplate builds archive names from the parsed source directory name.
+// Placeholders are written as {field} or {field:0N} to pad numbers to N
+// digits. The text before a placeholder belongs to it: when the field is
+// empty, as for a volume without a volume number, the text is left out too,
+// so "{series} v{volume:02}.{ext}" gives "Akira.cbz" for a directory named
+// "Akira".
+type NameTemplate struct {
+	text  string
+	parts []templatePart
+}
+
+// templatePart is a placeholder and the literal text before it
+type templatePart struct {
+	prefix string
+	field  string
+	width  int
+}
+
+// ParseNameTemplate parses an archive name template such as
+// "{series} v{volume:02} c{chapter:03}.{ext}"
+func ParseNameTemplate(text string) (*NameTemplate, error) {
+	if strings.ContainsAny(text, `/\`) {
+		return nil, fmt.Errorf("name template '%s' must not contain path separators", text)
+	}
+
+	t := &NameTemplate{text: text}
+	rest := text
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{prefix: rest})
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' in name template '%s'", text)
+		}
+		end += start
+
+		part := templatePart{prefix: rest[:start], field: rest[start+1 : end]}
+		if field, width, ok := strings.Cut(part.field, ":"); ok {
+			n, err := strconv.Atoi(width)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid width '%s' in name template '%s'", width, text)
+			}
+			part.field, part.width = field, n
+		}
+		if !isNameField(part.field) {
+			return nil, fmt.Errorf("unknown field '{%s}' in name template '%s' (use %s)", part.field, text, "{"+strings.Join(nameFields, "}, {")+"}")
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[end+1:]
+	}
+	return t, nil
+}
+
+// String returns the template text
+func (t *NameTemplate) String() string {
+	return t.text
+}
+
+// Render returns the archive file name for the source directory dirName.
+// Characters that are not allowed in file names are replaced, and the
+// directory name is used when the template renders no name at all.
+func (t *NameTemplate) Render(dirName, ext string) string {
+	book := ParseBookName(dirName)
+	values := map[string]string{
+		"name":     dirName,
+		"series":   book.Series,
+		"chapter":  book.Chapter,
+		"language": book.Language,
+		"ext":      ext,
+	}
+	if book.Volume != 0 {
+		values["volume"] = strconv.Itoa(book.Volume)
+	}
+	if book.Year != 0 {
+		values["year"] = strconv.Itoa(book.Year)
+	}
+
+	var name strings.Builder
+	for _, part := range t.parts {
+		if part.field == "" {
+			name.WriteString(part.prefix)
+			continue
+		}
+		value := sanitizeName(values[part.field])
+		if value == "" {
+			continue
+		}
+		name.WriteString(part.prefix)
+		name.WriteString(padNumber(value, part.width))
+	}
+
+	result := strings.TrimSpace(name.String())
+	if result == "" || strings.HasPrefix(result, "."+ext) {
+		result = sanitizeName(dirName) + result
+	}
+	return result
+}
+
+func isNameField(field string) bool {
+	for _, f := range nameFields {
+		if field == f {
+			return true
+		}
+	}
+	return false
+}
+
+// padNumber pads the whole part of a number with zeros to width digits, so
+// chapter "42.5" becomes "042.5" for width 3. Other values are unchanged.
+func padNumber(value string, width int) string {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if _, err := strconv.Atoi(whole); err != nil || len(whole) >= width {
+		return value
+	}
+	padded := strings.Repeat("0", width-len(whole)) + whole
+	if fraction != "" {
+		padded += "." + fraction
+	}
+	return padded
+}
+
+// sanitizeName replaces characters that are not allowed in file names on
+// Windows, macOS or Linux
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// CollisionMode controls what happens when an archive name is already taken
+type CollisionMode int
+
+const (
+	CollisionRename    CollisionMode = iota // Add a number: "Book (2).cbz"
+	CollisionOverwrite                      // Replace the existing archive
+	CollisionSkip                           // Leave the existing archive and skip the source
+)
+
+// String returns the name of the collision mode
+func (c CollisionMode) String() string {
+	switch c {
+	case CollisionOverwrite:
+		return "overwrite"
+	case CollisionSkip:
+		return "skip"
+	default:
+		return "rename"
+	}
+}
+
+// ParseCollisionMode parses a collision mode name (rename, overwrite or skip)
+func ParseCollisionMode(name string) (CollisionMode, error) {
+	switch strings.ToLower(name) {
+	case "rename":
+		return CollisionRename, nil
+	case "overwrite":
+		return CollisionOverwrite, nil
+	case "skip":
+		return CollisionSkip, nil
+	default:
+		return CollisionRename, fmt.Errorf("unknown collision mode '%s' (use rename, overwrite or skip)", name)
+	}
+}
+
+// ErrArchiveExists is returned by Namer.Path in skip mode when the archive
+// name is already taken
+var ErrArchiveExists = errors.New("archive already exists")
+
+// Namer picks archive paths from a template, making sure two sources never
+// write the same archive in one run and handling archives that already
+// exist according to the collision mode
+type Namer struct {
+	template  *NameTemplate
+	collision CollisionMode
+	taken     map[string]bool
+}
+
+// NewNamer creates a namer for the template text, DefaultNameTemplate if empty
+func NewNamer(template string, collision CollisionMode) (*Namer, error) {
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+	t, err := ParseNameTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	return &Namer{template: t, collision: collision, taken: make(map[string]bool)}, nil
+}
+
+// Path returns the path of the archive in outDir for the source directory
+// named dirName. In skip mode ErrArchiveExists is returned when the archive
+// exists on disk; names used earlier in the run are always renamed.
+func (n *Namer) Path(outDir, dirName, ext string) (string, error) {
+	name := n.template.Render(dirName, ext)
+	archivePath := filepath.Join(outDir, name)
+
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	for i := 2; n.taken[archivePath] || n.existsAndKept(archivePath); i++ {
+		if !n.taken[archivePath] && n.collision == CollisionSkip {
+			return "", fmt.Errorf("%s: %w", archivePath, ErrArchiveExists)
+		}
+		archivePath = filepath.Join(outDir, fmt.Sprintf("%s (%d)%s", stem, i, filepath.Ext(name)))
+	}
+
+	n.taken[archivePath] = true
+	return archivePath, nil
+}
+
+// existsAndKept reports whether an archive exists at path and must not be
+// overwritten
+func (n *Namer) existsAndKept(path string) bool {
+	if n.collision == CollisionOverwrite {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// OutputDir returns the directory an archive for a source in sourceDir is
+// written to, as MirrorDir does, creating the directories as needed
+func OutputDir(sourceRoot, outputRoot, sourceDir string) (string, error) {
+	outDir, err := MirrorDir(sourceRoot, outputRoot, sourceDir)
+	if err != nil || outputRoot == "" {
+		return outDir, err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory %s: %v", outDir, err)
+	}
+	return outDir, nil
+}
+
+// MirrorDir returns the directory an archive for a source in sourceDir
+// belongs in. Without an output root that is sourceDir itself; otherwise the
+// path of sourceDir relative to sourceRoot is mirrored below outputRoot.
+func MirrorDir(sourceRoot, outputRoot, sourceDir string) (string, error) {
+	if outputRoot == "" {
+		return sourceDir, nil
+	}
+
+	absRoot, err := filepath.Abs(sourceRoot)
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, absDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the source root %s", sourceDir, sourceRoot)
+	}
+
+	return filepath.Join(outputRoot, rel), nil
+}
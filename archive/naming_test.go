@@ -0,0 +1,135 @@
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNameTemplateRender(t *testing.T) {
+	tests := []struct {
+		template string
+		dirName  string
+		ext      string
+		want     string
+	}{
+		{DefaultNameTemplate, "One Piece v05 c042", "cbz", "One Piece v05 c042.cbz"},
+		{"{series} v{volume:02} c{chapter:03}.{ext}", "One Piece v5 c42", "cbz", "One Piece v05 c042.cbz"},
+		{"{series} v{volume:02} c{chapter:03}.{ext}", "Series 12.5", "cbr", "Series c012.5.cbr"},
+		{"{series} v{volume:02}.{ext}", "Akira", "cbz", "Akira.cbz"},
+		{"{series} {chapter:03}.{ext}", "Saga #1234", "cbz", "Saga 1234.cbz"},
+		{"{series} ({year}) v{volume:02} c{chapter:03}.{ext}", "Batman v1 (2023)", "cb7", "Batman (2023) v01.cb7"},
+		{"{series} [{language}].{ext}", "Dorohedoro v01 [Japanese]", "cbz", "Dorohedoro [ja].cbz"},
+		{"{series} {chapter:3}.{ext}", "Saga 7", "cbz", "Saga 007.cbz"},
+
+		// Characters not allowed in file names are replaced
+		{"{series}.{ext}", `What? If: "Yes" v1`, "cbz", "What_ If_ _Yes_.cbz"},
+		{DefaultNameTemplate, "A|B*C", "cbz", "A_B_C.cbz"},
+
+		// The directory name is used when the template renders no name
+		{"{series} {chapter:03}.{ext}", "Vol 5", "cbz", "Vol 5.cbz"},
+		{"{year}", "Plain Name", "cbz", "Plain Name"},
+		{"{series} v{volume:02}.{ext}", "Vol 5", "cbz", "v05.cbz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.dirName, func(t *testing.T) {
+			template, err := ParseNameTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseNameTemplate(%q): %v", tt.template, err)
+			}
+			if got := template.Render(tt.dirName, tt.ext); got != tt.want {
+				t.Errorf("Render(%q, %q) = %q, want %q", tt.dirName, tt.ext, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNameTemplateErrors(t *testing.T) {
+	for _, template := range []string{
+		"{series}/{name}.{ext}",
+		`{series}\{name}.{ext}`,
+		"{series.{ext}",
+		"{title}.{ext}",
+		"{chapter:x}.{ext}",
+		"{chapter:-1}.{ext}",
+	} {
+		if _, err := ParseNameTemplate(template); err == nil {
+			t.Errorf("ParseNameTemplate(%q) succeeded, want an error", template)
+		}
+	}
+}
+
+func TestNamerPath(t *testing.T) {
+	// Book.cbz exists on disk in every case
+	tests := []struct {
+		name      string
+		template  string
+		collision CollisionMode
+		dirNames  []string
+		want      []string // Archive names, "" for ErrArchiveExists
+	}{
+		{"new names", DefaultNameTemplate, CollisionRename, []string{"Other", "Third"}, []string{"Other.cbz", "Third.cbz"}},
+		{"rename existing", DefaultNameTemplate, CollisionRename, []string{"Book", "Book"}, []string{"Book (2).cbz", "Book (3).cbz"}},
+		{"overwrite existing", DefaultNameTemplate, CollisionOverwrite, []string{"Book", "Book"}, []string{"Book.cbz", "Book (2).cbz"}},
+		{"skip existing", DefaultNameTemplate, CollisionSkip, []string{"Book", "Other", "Other"}, []string{"", "Other.cbz", "Other (2).cbz"}},
+		{"same rendered name", "{series} v{volume:02}.{ext}", CollisionOverwrite, []string{"Akira v1", "Akira_v01", "Akira Vol. 1"}, []string{"Akira v01.cbz", "Akira v01 (2).cbz", "Akira v01 (3).cbz"}},
+		{"taken by a rename", DefaultNameTemplate, CollisionRename, []string{"Book", "Book (2)"}, []string{"Book (2).cbz", "Book (2) (2).cbz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(outDir, "Book.cbz"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			namer, err := NewNamer(tt.template, tt.collision)
+			if err != nil {
+				t.Fatalf("NewNamer: %v", err)
+			}
+
+			for i, dirName := range tt.dirNames {
+				got, err := namer.Path(outDir, dirName, "cbz")
+				if tt.want[i] == "" {
+					if !errors.Is(err, ErrArchiveExists) {
+						t.Errorf("Path(%q) = %q, %v, want ErrArchiveExists", dirName, got, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Path(%q): %v", dirName, err)
+				}
+				if want := filepath.Join(outDir, tt.want[i]); got != want {
+					t.Errorf("Path(%q) = %q, want %q", dirName, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorDir(t *testing.T) {
+	tests := []struct {
+		sourceRoot, outputRoot, sourceDir string
+		want                              string
+	}{
+		{"/library", "", "/library/Manga/Akira", "/library/Manga/Akira"},
+		{"/library", "/out", "/library/Manga/Akira", "/out/Manga/Akira"},
+		{"/library", "/out", "/library", "/out"},
+		{"/library", "/out", "/elsewhere/Akira", ""},
+		{"/library", "/out", "/library/../Akira", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := MirrorDir(filepath.FromSlash(tt.sourceRoot), filepath.FromSlash(tt.outputRoot), filepath.FromSlash(tt.sourceDir))
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("MirrorDir(%q, %q, %q) = %q, want an error", tt.sourceRoot, tt.outputRoot, tt.sourceDir, got)
+			}
+			continue
+		}
+		if want := filepath.FromSlash(tt.want); err != nil || got != want {
+			t.Errorf("MirrorDir(%q, %q, %q) = %q, %v, want %q", tt.sourceRoot, tt.outputRoot, tt.sourceDir, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// PlanSamples is the number of pages per archive converted to estimate its size
+const PlanSamples = 3
+
+// Plan describes an archive that would be created, without writing it
+type Plan struct {
+	Source        string   // Source directory, empty for loose files only
+	Archive       string   // Path of the archive, set by the caller
+	Images        []string // Images that would be converted
+	Copied        []string // Files that would be stored as-is
+	Skipped       []string // Files the filter leaves out
+	Failed        []string // Sampled images that could not be converted, which would stop the archive
+	InputSize     int64    // Size of the images and copied files
+	EstimatedSize int64    // Estimated size of the archive
+	Sampled       int      // Number of pages converted for the estimate
+}
+
+// PlanArchive works out what CreateArchive would store for sourceDir with
+// opts, without writing anything. The archive size is estimated by
+// converting up to samples images, spread over the book, with the real
+// encoder and scaling their size change to all images.
+func PlanArchive(sourceDir string, opts Options, samples int) (*Plan, error) {
+	plan := &Plan{Source: sourceDir}
+
+	var entries, skipped []Entry
+	if sourceDir != "" {
+		var err error
+		if entries, skipped, err = collectEntries(sourceDir, opts.Filter); err != nil {
+			return nil, err
+		}
+	}
+	opts.Log = nil
+	entries = attachEntries(entries, opts)
+
+	var images []Entry
+	var imageSize int64
+	for _, entry := range entries {
+		info, err := os.Stat(entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		plan.InputSize += info.Size()
+
+		if entry.IsImage {
+			images = append(images, entry)
+			plan.Images = append(plan.Images, entry.Name)
+			imageSize += info.Size()
+		} else {
+			plan.Copied = append(plan.Copied, entry.Name)
+		}
+	}
+	for _, entry := range skipped {
+		plan.Skipped = append(plan.Skipped, entry.Name)
+	}
+
+	// Convert a few pages spread over the book and scale their size change
+	// to all images
+	var sampleIn, sampleOut int64
+	count := min(samples, len(images))
+	for i := 0; i < count; i++ {
+		entry := images[(2*i+1)*len(images)/(2*count)]
+		in, out, err := sampleImage(entry, opts)
+		if storeUnchanged(err) {
+			// The image would be stored as it is
+			plan.Images = slices.DeleteFunc(plan.Images, func(name string) bool { return name == entry.Name })
+			plan.Copied = append(plan.Copied, entry.Name)
+			imageSize -= in
+			continue
+		} else if err != nil {
+			plan.Failed = append(plan.Failed, fmt.Sprintf("%s: %v", entry.Name, err))
+			continue
+		}
+		sampleIn += in
+		sampleOut += out
+		plan.Sampled++
+	}
+
+	plan.EstimatedSize = plan.InputSize
+	if sampleIn > 0 {
+		plan.EstimatedSize = plan.InputSize - imageSize + imageSize*sampleOut/sampleIn
+	}
+	return plan, nil
+}
+
+// sampleImage converts one image, returning its size before and after. The
+// size before is also returned when the conversion fails.
+func sampleImage(entry Entry, opts Options) (int64, int64, error) {
+	input, err := os.Open(entry.Path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer input.Close()
+
+	info, err := input.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pages, _, err := fileops.ConvertImage(input, entry.Name, opts.Image)
+	if err != nil {
+		return info.Size(), 0, err
+	}
+
+	var size int64
+	for _, page := range pages {
+		size += int64(len(page.Data))
+	}
+	return info.Size(), size, nil
+}
+
+// Summary describes the plan in a few lines for display: file counts, the
+// files copied, skipped or failing, and the size estimate. Long lists of
+// names are shortened.
+func (p *Plan) Summary() []string {
+	lines := []string{fmt.Sprintf("Images: %d, copied as-is: %d, skipped: %d", len(p.Images), len(p.Copied), len(p.Skipped))}
+	if len(p.Copied) > 0 {
+		lines = append(lines, "Copied: "+shortList(p.Copied))
+	}
+	if len(p.Skipped) > 0 {
+		lines = append(lines, "Skipped: "+shortList(p.Skipped))
+	}
+	for _, failed := range p.Failed {
+		lines = append(lines, "Cannot convert "+failed)
+	}
+
+	size := fmt.Sprintf("Size: %s -> about %s", FormatSize(p.InputSize), FormatSize(p.EstimatedSize))
+	if p.Sampled > 0 {
+		size += fmt.Sprintf(" (estimated from %d of %d pages)", p.Sampled, len(p.Images))
+	}
+	return append(lines, size)
+}
+
+// shortList joins names, listing at most five
+func shortList(names []string) string {
+	const limit = 5
+	if len(names) <= limit {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:limit], ", "), len(names)-limit)
+}
+
+// FormatSize formats a size in bytes for display, e.g. "12.3 MB"
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, suffix := float64(bytes)/unit, "KB"
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,222 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// VerifyArchive reopens the archive described by report, written for
+// sourceDir with opts, and checks that it is complete: every file of the
+// source went in, the archive holds exactly the entries the report lists and
+// every entry reads back, with each page and each image stored unchanged
+// decoding. The pages of RAR and 7Z archives cannot be read here: they are checked in the ZIP they are packed
+// from when the archive is created with opts.Verify, and the entries the rar
+// or 7z command lists are compared with the report.
+func VerifyArchive(sourceDir string, report *Report, opts Options) error {
+	if err := verifySources(sourceDir, report, opts); err != nil {
+		return err
+	}
+
+	if tool, ok := toolFor(ArchiveType(strings.TrimPrefix(filepath.Ext(report.Archive), "."))); ok {
+		return verifyListing(report, opts, tool)
+	}
+	return verifyZip(report, opts)
+}
+
+// verifySources checks that every file collected from sourceDir and
+// opts.Attach was stored
+func verifySources(sourceDir string, report *Report, opts Options) error {
+	opts.Log = nil
+
+	var entries []Entry
+	if sourceDir != "" {
+		var err error
+		if entries, err = CollectEntries(sourceDir, opts.Filter); err != nil {
+			return err
+		}
+	}
+	entries = attachEntries(entries, opts)
+
+	stored := make(map[string]bool)
+	for _, file := range report.Files {
+		stored[file.Source] = true
+	}
+
+	archiveInfo, err := os.Stat(report.Archive)
+	if err != nil {
+		return err
+	}
+	sources := 0
+	for _, entry := range entries {
+		if info, err := os.Stat(entry.Path); err == nil && os.SameFile(info, archiveInfo) {
+			continue
+		}
+		if !stored[entry.Name] {
+			return fmt.Errorf("%s was not stored", entry.Name)
+		}
+		sources++
+	}
+	if sources != len(report.Files) {
+		return fmt.Errorf("%d source files, but %d were stored", sources, len(report.Files))
+	}
+	return nil
+}
+
+// verifyZip checks the entries of a ZIP archive against the report, reading
+// each one in full so its checksum is verified and decoding the pages and
+// other images
+func verifyZip(report *Report, opts Options) error {
+	reader, err := zip.OpenReader(report.Archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	expected, pages := expectedEntries(report, opts)
+	if len(reader.File) != len(expected) {
+		return fmt.Errorf("archive has %d entries, expected %d", len(reader.File), len(expected))
+	}
+
+	for _, file := range reader.File {
+		if !expected[file.Name] {
+			return fmt.Errorf("unexpected entry %s", file.Name)
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file.Name, err)
+		}
+		if pages[file.Name] || fileops.IsImageData(file.Name, data) {
+			if err := fileops.VerifyImage(file.Name, data); err != nil {
+				return fmt.Errorf("%s: %v", file.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expectedEntries returns the entries an archive written for report holds,
+// and which of them are pages
+func expectedEntries(report *Report, opts Options) (expected, pages map[string]bool) {
+	expected = make(map[string]bool)
+	pages = make(map[string]bool)
+	for _, file := range report.Files {
+		for _, entry := range file.Entries {
+			expected[entry] = true
+			pages[entry] = file.Format != ""
+		}
+	}
+	if opts.ComicInfo != ComicInfoOff {
+		expected[ComicInfoName] = true
+	}
+	return expected, pages
+}
+
+// readZipFile reads an entry of a ZIP archive, failing if its checksum does
+// not match
+func readZipFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// verifyListing compares the files the archive tool lists in a RAR or 7Z
+// archive with the report
+func verifyListing(report *Report, opts Options, tool archiveTool) error {
+	if err := tool.available(); err != nil {
+		return err
+	}
+	cmd := exec.Command(tool.command, append(tool.list, report.Archive)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %v", report.Archive, err)
+	}
+
+	var names []string
+	if tool.command == sevenZipTool.command {
+		names = parse7zListing(string(out))
+	} else {
+		names = strings.Split(strings.TrimSpace(string(out)), "\n")
+	}
+	files := listedFiles(names)
+
+	expected, _ := expectedEntries(report, opts)
+	if len(files) != len(expected) {
+		return fmt.Errorf("archive has %d entries, expected %d", len(files), len(expected))
+	}
+	for _, name := range files {
+		if !expected[name] {
+			return fmt.Errorf("unexpected entry %s", name)
+		}
+	}
+	return nil
+}
+
+// parse7zListing returns the paths in the technical listing of "7z l -slt",
+// leaving out the archive itself and folders
+func parse7zListing(listing string) []string {
+	var names []string
+	var path string
+	inEntries, folder := false, false
+	flush := func() {
+		if path != "" && !folder {
+			names = append(names, path)
+		}
+		path, folder = "", false
+	}
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "----------") {
+			inEntries = true
+			continue
+		}
+		if !inEntries {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " = ")
+		switch {
+		case key == "Path":
+			flush()
+			path = value
+		case key == "Folder" && value == "+", key == "Attributes" && strings.HasPrefix(value, "D"):
+			folder = true
+		}
+	}
+	flush()
+	return names
+}
+
+// listedFiles normalizes the names an archive tool lists to slash separated
+// paths, dropping empty lines and directories, which are listed as the
+// parent of other names
+func listedFiles(names []string) []string {
+	set := make(map[string]bool)
+	for _, name := range names {
+		if name = filepath.ToSlash(strings.TrimSpace(name)); name != "" {
+			set[name] = true
+		}
+	}
+	var files []string
+	for name := range set {
+		isDir := false
+		for other := range set {
+			if strings.HasPrefix(other, name+"/") {
+				isDir = true
+				break
+			}
+		}
+		if !isDir {
+			files = append(files, name)
+		}
+	}
+	return files
+}
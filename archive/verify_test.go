@@ -0,0 +1,88 @@
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"testing"
+
+	"scottgcooper-cbz-webp-converter/fileops"
+)
+
+// writeZip writes a ZIP archive holding the given entries
+func writeZip(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	for name, data := range entries {
+		if err := addDataToZip(zipWriter, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyArchiveCreated(t *testing.T) {
+	page := jpegData(t, 40, 60)
+	dir := writeFiles(t, map[string][]byte{
+		"01.jpg":    page,
+		"02.webp":   animatedWebPData(t, 30, 30),
+		"notes.txt": []byte("notes"),
+	})
+	opts := Options{Image: fileops.DefaultOptions()}
+	report, err := CreateZipArchive(dir, dir+".cbz", opts)
+	if err != nil {
+		t.Fatalf("CreateZipArchive: %v", err)
+	}
+	if err := VerifyArchive(dir, report, opts); err != nil {
+		t.Errorf("VerifyArchive: %v", err)
+	}
+}
+
+func TestVerifyArchive(t *testing.T) {
+	page := jpegData(t, 40, 60)
+	sources := map[string][]byte{"01.jpg": page, "02.jpg": page, "notes.txt": []byte("notes")}
+	report := func() *Report {
+		return &Report{Files: []FileReport{
+			{Source: "01.jpg", Entries: []string{"01.jpg"}},
+			{Source: "02.jpg", Entries: []string{"02.jpg"}},
+			{Source: "notes.txt", Entries: []string{"notes.txt"}},
+		}}
+	}
+	tests := []struct {
+		name    string
+		entries map[string][]byte
+		report  *Report
+		ok      bool
+	}{
+		{"complete", map[string][]byte{"01.jpg": page, "02.jpg": page, "notes.txt": []byte("notes")}, report(), true},
+		{"truncated image", map[string][]byte{"01.jpg": page, "02.jpg": page[:len(page)/2], "notes.txt": []byte("notes")}, report(), false},
+		{"text with an image name", map[string][]byte{"01.jpg": page, "02.jpg": []byte("text"), "notes.txt": []byte("notes")}, report(), false},
+		{"missing entry", map[string][]byte{"01.jpg": page, "notes.txt": []byte("notes")}, report(), false},
+		{"extra entry", map[string][]byte{"01.jpg": page, "02.jpg": page, "03.jpg": page, "notes.txt": []byte("notes")}, report(), false},
+		{"source not stored", map[string][]byte{"01.jpg": page, "notes.txt": []byte("notes")}, &Report{Files: []FileReport{
+			{Source: "01.jpg", Entries: []string{"01.jpg"}},
+			{Source: "notes.txt", Entries: []string{"notes.txt"}},
+		}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, sources)
+			tt.report.Archive = dir + ".cbz"
+			writeZip(t, tt.report.Archive, tt.entries)
+
+			err := VerifyArchive(dir, tt.report, Options{ComicInfo: ComicInfoOff})
+			if tt.ok && err != nil {
+				t.Errorf("VerifyArchive: %v", err)
+			} else if !tt.ok && err == nil {
+				t.Errorf("VerifyArchive succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ReadDir lists the files and directories in dir that the hidden file and
+// symlink policy of filter allows. Followed links are described by their
+// target under the link's name; broken links and special files such as
+// sockets, pipes and devices are left out.
+func ReadDir(dir string, filter Filter) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []fs.FileInfo
+	for _, entry := range entries {
+		if !filter.IncludeHidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if filter.Symlinks == SymlinkSkip {
+				continue
+			}
+			if info, err = os.Stat(filepath.Join(dir, entry.Name())); err != nil {
+				continue
+			}
+		}
+
+		if info.IsDir() || info.Mode().IsRegular() {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// walkFiles calls fn for every file below root that the filter's hidden
+// file and symlink policy allows, with its path relative to root using
+// forward slashes. Each directory is visited once, so a link back to a
+// directory already walked is skipped instead of looping.
+func walkFiles(root string, filter Filter, fn func(filePath, relPath string) error) error {
+	visited := make(map[string]bool)
+
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if visited[realDir] {
+			return nil
+		}
+		visited[realDir] = true
+
+		infos, err := ReadDir(dir, filter)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			filePath, relPath := filepath.Join(dir, info.Name()), path.Join(rel, info.Name())
+			if info.IsDir() {
+				err = walk(filePath, relPath)
+			} else {
+				err = fn(filePath, relPath)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, ".")
+}
@@ -0,0 +1,153 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// symlinkTree creates a directory with hidden files, broken links, and
+// links to files and to directories, some of them looping
+func symlinkTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"a/b", ".hidden", "outside"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"a/01.jpg", "a/b/02.jpg", ".hidden/03.jpg", ".cover.jpg", "outside/04.jpg"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"a/b/up":     "..",           // Loops back to a
+		"a/self":     ".",            // Loops to itself
+		"a/copy.jpg": "01.jpg",       // A file
+		"a/extra":    "../outside",   // A directory outside a
+		"a/broken":   "missing.jpg",  // Nothing
+		"a/chain":    "b/../b/../b/", // A directory, reached through a longer path
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symbolic links unavailable: %v", err)
+		}
+	}
+	return root
+}
+
+func TestWalkFiles(t *testing.T) {
+	root := symlinkTree(t)
+	tests := []struct {
+		name   string
+		dir    string
+		filter Filter
+		want   []string
+	}{
+		{
+			name:   "follow",
+			dir:    "a",
+			filter: Filter{},
+			want:   []string{"01.jpg", "b/02.jpg", "copy.jpg", "extra/04.jpg"},
+		},
+		{
+			name:   "skip",
+			dir:    "a",
+			filter: Filter{Symlinks: SymlinkSkip},
+			want:   []string{"01.jpg", "b/02.jpg"},
+		},
+		{
+			name:   "hidden left out",
+			dir:    ".",
+			filter: Filter{Symlinks: SymlinkSkip},
+			want:   []string{"a/01.jpg", "a/b/02.jpg", "outside/04.jpg"},
+		},
+		{
+			name:   "hidden included",
+			dir:    ".",
+			filter: Filter{Symlinks: SymlinkSkip, IncludeHidden: true},
+			want:   []string{".cover.jpg", ".hidden/03.jpg", "a/01.jpg", "a/b/02.jpg", "outside/04.jpg"},
+		},
+		{
+			name:   "each directory once",
+			dir:    ".",
+			filter: Filter{},
+			want:   []string{"a/01.jpg", "a/b/02.jpg", "a/copy.jpg", "a/extra/04.jpg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := walkFiles(filepath.Join(root, tt.dir), tt.filter, func(filePath, relPath string) error {
+				if _, err := os.Stat(filePath); err != nil {
+					t.Errorf("walked %s, which cannot be read: %v", relPath, err)
+				}
+				got = append(got, relPath)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("walkFiles: %v", err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("walked %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	root := symlinkTree(t)
+	tests := []struct {
+		filter Filter
+		want   []string // Names, with a slash after directories
+	}{
+		{Filter{}, []string{"01.jpg", "b/", "chain/", "copy.jpg", "extra/", "self/"}},
+		{Filter{Symlinks: SymlinkSkip}, []string{"01.jpg", "b/"}},
+	}
+
+	for _, tt := range tests {
+		infos, err := ReadDir(filepath.Join(root, "a"), tt.filter)
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		var got []string
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() {
+				name += "/"
+			}
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("ReadDir with symlinks %v listed %q, want %q", tt.filter.Symlinks, got, tt.want)
+		}
+	}
+}
+
+func TestParseSymlinkMode(t *testing.T) {
+	tests := []struct {
+		name string
+		want SymlinkMode
+		ok   bool
+	}{
+		{"follow", SymlinkFollow, true},
+		{"Skip", SymlinkSkip, true},
+		{"ignore", SymlinkFollow, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSymlinkMode(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseSymlinkMode(%q) = %v, %v", tt.name, got, err)
+		}
+		if tt.ok && !strings.EqualFold(got.String(), tt.name) {
+			t.Errorf("%v.String() = %q, want %q", got, got.String(), tt.name)
+		}
+	}
+}
@@ -13,8 +13,17 @@ NC='\033[0m' # No Color
 
 echo -e "${GREEN}Building CBZ WebP Converter for multiple platforms...${NC}"
 
+# Set STATIC=1 to build static binaries without cgo. These use the built-in
+# pure-Go encoder and write lossless WebP pages.
+if [ "$STATIC" = "1" ]; then
+    cgo=0
+    echo -e "${YELLOW}Building static binaries (lossless WebP only)${NC}"
+else
+    cgo=1
+fi
+
 # Check if WebP library is available
-if ! pkg-config --exists libwebp; then
+if [ "$cgo" = "1" ] && ! pkg-config --exists libwebp; then
     echo -e "${YELLOW}Warning: libwebp not found. Some builds may fail.${NC}"
     echo -e "${YELLOW}Install with: brew install webp (macOS) or apt-get install libwebp-dev (Ubuntu)${NC}"
 fi
@@ -44,7 +53,7 @@ for platform in "${platforms[@]}"; do
         ext=""
     fi
     
-    CGO_ENABLED=1 GOOS=$os GOARCH=$arch go build -ldflags="-s -w" -o "cbz-converter-$os-$arch$ext" ../main.go
+    CGO_ENABLED=$cgo GOOS=$os GOARCH=$arch go build -ldflags="-s -w" -o "cbz-converter-$os-$arch$ext" ../main.go
     
     if [ $? -eq 0 ]; then
         echo -e "${GREEN}✓ Built cbz-converter-$os-$arch$ext${NC}"
@@ -0,0 +1,396 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"scottgcooper-cbz-webp-converter/archive"
+	"scottgcooper-cbz-webp-converter/fileops"
+	"scottgcooper-cbz-webp-converter/trash"
+)
+
+// Run executes the non-interactive converter with the given command line arguments
+func Run(args []string) error {
+	fs := flag.NewFlagSet("cbz-converter --cli", flag.ContinueOnError)
+	presetName := fs.String("preset", fileops.Presets[0].Name, "device preset ("+strings.Join(fileops.PresetNames(), ", ")+")")
+	format := fs.String("format", "", "archive format (cbz, cbr, cb7z), defaults to the preset's format")
+	imageFormat := fs.String("image-format", "", "page image format ("+strings.Join(fileops.EncoderNames(), ", ")+"), defaults to WebP")
+	quality := fs.Float64("quality", 0, "image quality (0-100), overrides the preset")
+	grayscale := fs.String("grayscale", "", "grayscale conversion (off, auto, force), overrides the preset")
+	autoCrop := fs.Bool("autocrop", false, "trim uniform page margins, overrides the preset")
+	cropLimit := fs.Float64("crop-limit", 0, "largest percentage of the width or height auto-crop may remove")
+	splitSpreads := fs.Bool("split-spreads", false, "split landscape double-page spreads into two pages, overrides the preset")
+	colorProfile := fs.String("color-profile", "srgb", "embedded ICC profiles: convert pages to sRGB (srgb) or keep the profile in WebP pages (embed)")
+	metadata := fs.String("metadata", "strip", "EXIF and XMP metadata: remove it (strip) or store it in WebP pages (keep)")
+	rightToLeft := fs.Bool("rtl", false, "pages are read right to left (manga), so the right half of a spread comes first")
+	renumber := fs.Bool("renumber", false, "name pages 0001.webp, 0002.webp... in reading order")
+	comicInfo := fs.String("comicinfo", "merge", "ComicInfo.xml: generate and merge an existing one (merge), keep an existing one unchanged (preserve) or skip it (off)")
+	nameTemplate := fs.String("name-template", archive.DefaultNameTemplate, "archive name template, with the fields {name}, {series}, {volume}, {chapter}, {year}, {language} and {ext}, e.g. \"{series} v{volume:02} c{chapter:03}.{ext}\"")
+	onCollision := fs.String("on-collision", "rename", "when the archive already exists: add a number (rename), replace it (overwrite) or skip the directory (skip)")
+	outputRoot := fs.String("output", "", "write archives below this directory, mirroring the directories' paths relative to -source-root, instead of next to the sources")
+	sourceRoot := fs.String("source-root", "", "directory whose hierarchy is mirrored below -output, defaults to the parent directory of each argument")
+	looseFiles := fs.String("loose-files", "ignore", "with -recursive, files next to book directories: store them in an archive of their own (archive), add them to each book's archive (attach) or leave them out (ignore)")
+	recursive := fs.Bool("recursive", false, "archive every directory below the given ones that holds images (e.g. Series/Volume/Chapter), mirroring below -output relative to the given directory")
+	var include, exclude patternList
+	fs.Var(&include, "include", "store only files matching these glob patterns, comma separated or repeated (e.g. \"*.jpg,*.png\")")
+	fs.Var(&exclude, "exclude", "leave out files matching these glob patterns, comma separated or repeated")
+	imagesOnly := fs.Bool("images-only", false, "store only images (and ComicInfo.xml)")
+	hidden := fs.Bool("hidden", false, "include files and directories whose name starts with a dot")
+	symlinks := fs.String("symlinks", "follow", "symbolic links: follow them, skipping loops (follow), or leave them out (skip)")
+	keepJunk := fs.Bool("keep-junk", false, "also store Thumbs.db, desktop.ini, .nfo, .url and similar files")
+	dryRun := fs.Bool("dry-run", false, "list the archives that would be created with their contents and estimated sizes, without writing anything")
+	samples := fs.Int("samples", archive.PlanSamples, "pages per archive converted to estimate its size with -dry-run")
+	reportPath := fs.String("report", "", "write which archive entry each source file became to this file")
+	deleteOriginal := fs.Bool("delete", false, "move source directories to the trash after conversion, once their archive is verified")
+	quarantine := fs.String("quarantine", "", "with -delete, move originals to this directory instead of the default trash (the XDG trash on Linux)")
+	verify := fs.Bool("verify", false, "reopen each archive after writing it and check that every file went in and every page decodes (always done with -delete)")
+	listPresets := fs.Bool("list-presets", false, "list the available device presets and exit")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cbz-converter --cli [options] <directory>...")
+		fmt.Fprintln(fs.Output(), "Each directory is converted into its own archive next to the source, or below -output.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	if *listPresets {
+		for _, preset := range fileops.Presets {
+			fmt.Printf("%-10s %s (%s)\n", preset.Name, preset.Description, preset.Format)
+		}
+		return nil
+	}
+
+	preset, ok := fileops.FindPreset(*presetName)
+	if !ok {
+		return fmt.Errorf("unknown preset '%s' (available: %s)", *presetName, strings.Join(fileops.PresetNames(), ", "))
+	}
+
+	opts := preset.Options
+	if *imageFormat != "" {
+		encoder, err := fileops.FindEncoder(*imageFormat)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Available(); err != nil {
+			return err
+		}
+		opts.OutputFormat = encoder.Name()
+	}
+	if *quality > 0 {
+		opts.Quality = float32(*quality)
+	}
+	if *grayscale != "" {
+		mode, err := fileops.ParseGrayscaleMode(*grayscale)
+		if err != nil {
+			return err
+		}
+		opts.Grayscale = mode
+		if opts.GrayTolerance == 0 {
+			opts.GrayTolerance = fileops.DefaultGrayTolerance
+		}
+	}
+	if setFlags["autocrop"] {
+		opts.AutoCrop = *autoCrop
+	}
+	if *cropLimit > 0 {
+		opts.MaxCropPercent = *cropLimit
+	}
+	if setFlags["split-spreads"] {
+		opts.SplitSpreads = *splitSpreads
+	}
+	opts.RightToLeft = *rightToLeft
+	mode, err := fileops.ParseColorProfileMode(*colorProfile)
+	if err != nil {
+		return err
+	}
+	opts.ColorProfile = mode
+	metadataMode, err := fileops.ParseMetadataMode(*metadata)
+	if err != nil {
+		return err
+	}
+	opts.Metadata = metadataMode
+
+	archiveFormat := preset.Format
+	if *format != "" {
+		archiveFormat = strings.ToLower(*format)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no directories given")
+	}
+
+	comicInfoMode, err := archive.ParseComicInfoMode(*comicInfo)
+	if err != nil {
+		return err
+	}
+
+	collision, err := archive.ParseCollisionMode(*onCollision)
+	if err != nil {
+		return err
+	}
+	namer, err := archive.NewNamer(*nameTemplate, collision)
+	if err != nil {
+		return err
+	}
+
+	symlinkMode, err := archive.ParseSymlinkMode(*symlinks)
+	if err != nil {
+		return err
+	}
+	filter := archive.Filter{
+		Include:       include,
+		Exclude:       exclude,
+		ImagesOnly:    *imagesOnly,
+		KeepJunk:      *keepJunk,
+		IncludeHidden: *hidden,
+		Symlinks:      symlinkMode,
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	archiveOpts := archive.Options{
+		Image:         opts,
+		RenumberPages: *renumber,
+		ComicInfo:     comicInfoMode,
+		Filter:        filter,
+		Verify:        *verify || *deleteOriginal,
+		Log:           os.Stdout,
+	}
+
+	looseMode, err := archive.ParseLooseFileMode(*looseFiles)
+	if err != nil {
+		return err
+	}
+
+	var bin *trash.Trash
+	if *deleteOriginal && !*dryRun {
+		if bin, err = trash.New(*quarantine); err != nil {
+			return err
+		}
+	}
+
+	// Collect the directories to archive, with the root their location
+	// below -output is relative to. Loose files stored in an archive of
+	// their own are a book without a source directory, named after the
+	// directory holding them.
+	type book struct {
+		dir, root string
+		loose     bool
+		attach    []string
+	}
+	var books []book
+	for _, dir := range fs.Args() {
+		dir = filepath.Clean(dir)
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+
+		if !*recursive {
+			root := filepath.Dir(dir)
+			if setFlags["source-root"] {
+				root = *sourceRoot
+			}
+			books = append(books, book{dir: dir, root: root})
+			continue
+		}
+
+		leaves, err := archive.FindBookDirectories(dir, filter)
+		if err != nil {
+			return err
+		}
+		if len(leaves) == 0 {
+			fmt.Printf("No images found below %s\n", dir)
+		}
+		rootFor := func(rel string) string {
+			if setFlags["source-root"] {
+				return *sourceRoot
+			} else if rel == "." {
+				return filepath.Dir(dir)
+			}
+			return dir
+		}
+
+		loose := make(map[string][]string)
+		for _, leaf := range leaves {
+			var attach []string
+			if parent := path.Dir(leaf); leaf != "." && looseMode != archive.LooseIgnore {
+				files, seen := loose[parent]
+				if !seen {
+					parentDir := filepath.Join(dir, filepath.FromSlash(parent))
+					if files, err = archive.LooseFiles(parentDir, filter); err != nil {
+						return err
+					}
+					loose[parent] = files
+					if looseMode == archive.LooseArchive && len(files) > 0 {
+						books = append(books, book{dir: parentDir, root: rootFor(parent), loose: true, attach: files})
+					}
+				}
+				if looseMode == archive.LooseAttach {
+					attach = files
+				}
+			}
+			books = append(books, book{dir: filepath.Join(dir, filepath.FromSlash(leaf)), root: rootFor(leaf), attach: attach})
+		}
+	}
+
+	var reports []*archive.Report
+	var plans []*archive.Plan
+	for _, b := range books {
+		dir := b.dir
+		source := dir
+		if b.loose {
+			source = ""
+		}
+		bookOpts := archiveOpts
+		bookOpts.Name = filepath.Base(dir)
+		bookOpts.Attach = b.attach
+
+		// A dry run must not create the output directories
+		outputDir := archive.OutputDir
+		if *dryRun {
+			outputDir = archive.MirrorDir
+		}
+		outDir, err := outputDir(b.root, *outputRoot, filepath.Dir(dir))
+		if err != nil {
+			return err
+		}
+
+		archivePath, err := namer.Path(outDir, filepath.Base(dir), archiveFormat)
+		if errors.Is(err, archive.ErrArchiveExists) {
+			fmt.Printf("Skipping %s: %v\n", dir, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			plan, err := archive.PlanArchive(source, bookOpts, *samples)
+			if err != nil {
+				return fmt.Errorf("failed to plan %s: %v", dir, err)
+			}
+			plan.Archive = archivePath
+			printPlan(dir, plan)
+			plans = append(plans, plan)
+			continue
+		}
+
+		report, err := archive.CreateArchive(source, archivePath, archive.ArchiveType(archiveFormat), bookOpts)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %v", dir, err)
+		}
+		reports = append(reports, report)
+
+		if *verify || *deleteOriginal {
+			if err := archive.VerifyArchive(source, report, bookOpts); err != nil {
+				return fmt.Errorf("failed to verify %s, the originals were kept: %v", archivePath, err)
+			}
+			fmt.Printf("Verified %s\n", archivePath)
+		}
+
+		// Attached files are left in place, as they went into several
+		// archives
+		if *deleteOriginal && b.loose {
+			for _, file := range b.attach {
+				if _, err := bin.Move(file, archivePath); err != nil {
+					return err
+				}
+			}
+		} else if *deleteOriginal {
+			if _, err := bin.Move(dir, archivePath); err != nil {
+				return err
+			}
+		}
+		if *deleteOriginal {
+			fmt.Printf("Moved the originals to %s\n", bin.Dir)
+		}
+	}
+
+	if *dryRun {
+		var images int
+		var inputSize, estimatedSize int64
+		for _, plan := range plans {
+			images += len(plan.Images)
+			inputSize += plan.InputSize
+			estimatedSize += plan.EstimatedSize
+		}
+		fmt.Printf("Dry run: %d archives, %d images, %s -> about %s. Nothing was written.\n",
+			len(plans), images, archive.FormatSize(inputSize), archive.FormatSize(estimatedSize))
+		return nil
+	}
+
+	if *reportPath != "" {
+		if err := writeReport(*reportPath, reports); err != nil {
+			return fmt.Errorf("failed to write report: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// printPlan prints what would be archived for dir
+func printPlan(dir string, plan *archive.Plan) {
+	action := ""
+	if _, err := os.Stat(plan.Archive); err == nil {
+		action = " (replaces the existing archive)"
+	}
+	fmt.Printf("%s -> %s%s\n", dir, plan.Archive, action)
+	for _, line := range plan.Summary() {
+		fmt.Printf("  %s\n", line)
+	}
+}
+
+// patternList is a flag holding glob patterns, given comma separated or by
+// repeating the flag
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			*p = append(*p, pattern)
+		}
+	}
+	return nil
+}
+
+// writeReport writes the page mapping of each archive to path
+func writeReport(path string, reports []*archive.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, report := range reports {
+		fmt.Fprintf(file, "%s\n", report.Archive)
+		if err := report.WriteMapping(file); err != nil {
+			return err
+		}
+		fmt.Fprintln(file)
+	}
+	return file.Close()
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"scottgcooper-cbz-webp-converter/trash"
+)
+
+// RunTrash lists, restores or purges the originals moved to the trash by
+// -delete, with the given command line arguments
+func RunTrash(args []string) error {
+	fs := flag.NewFlagSet("cbz-converter --trash", flag.ContinueOnError)
+	quarantine := fs.String("quarantine", "", "trash directory the originals were moved to, defaults to the XDG trash on Linux")
+	olderThan := fs.String("older-than", "30d", "with purge, only delete originals that went to the trash longer ago than this (e.g. 30d, 12h, 0 for all)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cbz-converter --trash list|restore|purge [options] [path...]")
+		fmt.Fprintln(fs.Output(), "  list     show the originals in the trash and the archives they went into")
+		fmt.Fprintln(fs.Output(), "  restore  move the originals at or below the given paths back")
+		fmt.Fprintln(fs.Output(), "  purge    delete originals older than -older-than for good, all of them or those below the given paths")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	// Options may come before or after the command
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no trash command given")
+	}
+	command := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+
+	bin, err := trash.New(*quarantine)
+	if err != nil {
+		return err
+	}
+	items, err := bin.List()
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		if len(items) == 0 {
+			fmt.Printf("No originals in %s\n", bin.Dir)
+		}
+		for _, item := range items {
+			fmt.Printf("%s  %s -> %s\n", item.Deleted.Format("2006-01-02 15:04"), item.Path, item.Archive)
+		}
+
+	case "restore":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("no paths given, name the originals or a directory above them")
+		}
+		restored := 0
+		for _, item := range items {
+			if !underAny(item.Path, fs.Args()) {
+				continue
+			}
+			if err := bin.Restore(item); err != nil {
+				return err
+			}
+			fmt.Printf("Restored %s\n", item.Path)
+			restored++
+		}
+		if restored == 0 {
+			fmt.Printf("No originals in %s at or below %s\n", bin.Dir, strings.Join(fs.Args(), ", "))
+		}
+
+	case "purge":
+		age, err := trash.ParseAge(*olderThan)
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-age)
+		purged := 0
+		for _, item := range items {
+			if !item.Deleted.Before(cutoff) || (fs.NArg() > 0 && !underAny(item.Path, fs.Args())) {
+				continue
+			}
+			if err := bin.Purge(item); err != nil {
+				return err
+			}
+			fmt.Printf("Purged %s\n", item.Path)
+			purged++
+		}
+		fmt.Printf("Purged %d originals older than %s\n", purged, *olderThan)
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown trash command '%s' (use list, restore or purge)", command)
+	}
+
+	return nil
+}
+
+// underAny reports whether path is one of paths or inside one of them
+func underAny(path string, paths []string) bool {
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if path == abs || strings.HasPrefix(path, abs+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package fileops
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/gif"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// defaultFrameDelay is the frame duration browsers use for GIF delays of 0 or 1
+const defaultFrameDelay = 100
+
+// Animation is an animated image with fully composited frames
+type Animation struct {
+	Frames    []image.Image // Full-canvas frames in display order
+	Delays    []int         // Frame durations in milliseconds
+	LoopCount int           // Number of times to play the animation, 0 for forever
+}
+
+// DecodeAnimation decodes all frames of a GIF. Each frame is composited onto
+// the canvas according to the GIF disposal methods, so every returned frame
+// is a complete picture that can be shown without its predecessors.
+func DecodeAnimation(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &Animation{}
+	switch {
+	case g.LoopCount == 0:
+		anim.LoopCount = 0
+	case g.LoopCount < 0:
+		anim.LoopCount = 1
+	default:
+		// GIF counts repeats after the first play, WebP counts plays
+		anim.LoopCount = g.LoopCount + 1
+	}
+
+	canvasRect := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if canvasRect.Empty() && len(g.Image) > 0 {
+		canvasRect = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(canvasRect)
+
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, cloneRGBA(canvas))
+
+		delay := defaultFrameDelay
+		if i < len(g.Delay) && g.Delay[i] > 1 {
+			delay = g.Delay[i] * 10
+		}
+		anim.Delays = append(anim.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return anim, nil
+}
+
+// ProcessAnimation applies the resize and color conversions from opts to
+// every frame. Cropping and spread splitting only apply to still pages.
+func ProcessAnimation(anim *Animation, opts Options) *Animation {
+	processed := &Animation{
+		Delays:    anim.Delays,
+		LoopCount: anim.LoopCount,
+	}
+	for _, frame := range anim.Frames {
+		processed.Frames = append(processed.Frames, finishPage(frame, opts))
+	}
+	return processed
+}
+
+// EncodeAnimatedWebP encodes an animation as an animated WebP. Frames are
+// stored whole and replace the previous frame (no blending, no disposal),
+// which reproduces the composited GIF exactly.
+func EncodeAnimatedWebP(anim *Animation, quality float32) ([]byte, error) {
+	return encodeAnimatedWebP(anim, quality, imageMetadata{})
+}
+
+// encodeAnimatedWebP is EncodeAnimatedWebP, storing the ICC profile, EXIF
+// and XMP data from meta in their own chunks
+func encodeAnimatedWebP(anim *Animation, quality float32, meta imageMetadata) ([]byte, error) {
+	bounds := anim.Frames[0].Bounds()
+
+	flags := byte(webpFlagAnimation)
+	for _, frame := range anim.Frames {
+		if !isOpaque(frame) {
+			flags |= webpFlagAlpha
+			break
+		}
+	}
+	if meta.iccProfile != nil {
+		flags |= webpFlagICC
+	}
+	if meta.exif != nil {
+		flags |= webpFlagEXIF
+	}
+	if meta.xmp != nil {
+		flags |= webpFlagXMP
+	}
+
+	// The loop count field has 16 bits; larger counts play as often as it allows
+	animData := make([]byte, 6)
+	binary.LittleEndian.PutUint16(animData[4:], uint16(min(anim.LoopCount, 0xFFFF)))
+
+	chunks := []riffChunk{vp8xChunk(flags, bounds.Dx(), bounds.Dy())}
+	if meta.iccProfile != nil {
+		chunks = append(chunks, riffChunk{FourCC: "ICCP", Data: meta.iccProfile})
+	}
+	chunks = append(chunks, riffChunk{FourCC: "ANIM", Data: animData})
+
+	for i, frame := range anim.Frames {
+		encoded, err := ConvertToWebP(frame, quality)
+		if err != nil {
+			return nil, err
+		}
+		bitstream, err := bitstreamChunks(encoded)
+		if err != nil {
+			return nil, err
+		}
+
+		var frameData bytes.Buffer
+		header := make([]byte, 16)
+		putUint24(header[6:], frame.Bounds().Dx()-1)
+		putUint24(header[9:], frame.Bounds().Dy()-1)
+		putUint24(header[12:], anim.Delays[i])
+		header[15] = 0x02 // Do not blend, do not dispose
+		frameData.Write(header)
+		writeChunks(&frameData, bitstream)
+
+		chunks = append(chunks, riffChunk{FourCC: "ANMF", Data: frameData.Bytes()})
+	}
+	if meta.exif != nil {
+		chunks = append(chunks, riffChunk{FourCC: "EXIF", Data: meta.exif})
+	}
+	if meta.xmp != nil {
+		chunks = append(chunks, riffChunk{FourCC: "XMP ", Data: meta.xmp})
+	}
+
+	return writeWebP(chunks), nil
+}
+
+// cloneRGBA returns a copy of img
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Rect)
+	copy(clone.Pix, img.Pix)
+	return clone
+}
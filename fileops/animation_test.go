@@ -0,0 +1,190 @@
+package fileops
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// gifData encodes a GIF animation of solid color frames
+func gifData(t *testing.T, frames, loopCount int) []byte {
+	t.Helper()
+	g := &gif.GIF{LoopCount: loopCount}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 24, 16), palette.Plan9)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i * 40)
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 5)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// webpChunk returns the first chunk with the given FourCC
+func webpChunk(t *testing.T, data []byte, fourCC string) []byte {
+	t.Helper()
+	chunks, err := parseWebP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if chunk.FourCC == fourCC {
+			return chunk.Data
+		}
+	}
+	return nil
+}
+
+func TestDecodeAnimationLoopCount(t *testing.T) {
+	tests := []struct {
+		gifLoops int
+		want     int
+	}{
+		{0, 0},  // Forever
+		{-1, 1}, // Play once
+		{1, 2},
+		{65535, 65536},
+	}
+
+	for _, tt := range tests {
+		anim, err := DecodeAnimation(bytes.NewReader(gifData(t, 2, tt.gifLoops)))
+		if err != nil {
+			t.Fatalf("DecodeAnimation: %v", err)
+		}
+		if anim.LoopCount != tt.want {
+			t.Errorf("GIF loop count %d decoded as %d, want %d", tt.gifLoops, anim.LoopCount, tt.want)
+		}
+	}
+}
+
+func TestEncodeAnimatedWebPLoopCount(t *testing.T) {
+	tests := []struct {
+		loops, want int
+	}{
+		{0, 0},
+		{3, 3},
+		{0xFFFF, 0xFFFF},
+		{0x10000, 0xFFFF},
+		{1 << 20, 0xFFFF},
+	}
+
+	for _, tt := range tests {
+		anim := &Animation{
+			Frames:    []image.Image{image.NewRGBA(image.Rect(0, 0, 4, 4)), image.NewRGBA(image.Rect(0, 0, 4, 4))},
+			Delays:    []int{100, 100},
+			LoopCount: tt.loops,
+		}
+		data, err := EncodeAnimatedWebP(anim, 80)
+		if err != nil {
+			t.Fatalf("EncodeAnimatedWebP: %v", err)
+		}
+		animData := webpChunk(t, data, "ANIM")
+		if got := int(binary.LittleEndian.Uint16(animData[4:])); got != tt.want {
+			t.Errorf("loop count %d stored as %d, want %d", tt.loops, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeAnimatedWebPMetadata(t *testing.T) {
+	anim := &Animation{
+		Frames: []image.Image{image.NewRGBA(image.Rect(0, 0, 4, 4)), image.NewRGBA(image.Rect(0, 0, 4, 4))},
+		Delays: []int{100, 100},
+	}
+	for _, frame := range anim.Frames {
+		frame.(*image.RGBA).Set(1, 1, color.RGBA{255, 0, 0, 255})
+	}
+	meta := imageMetadata{iccProfile: []byte("profile"), exif: []byte("exif"), xmp: []byte("xmp")}
+	data, err := encodeAnimatedWebP(anim, 80, meta)
+	if err != nil {
+		t.Fatalf("encodeAnimatedWebP: %v", err)
+	}
+
+	chunks, err := parseWebP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, chunk := range chunks {
+		order = append(order, chunk.FourCC)
+	}
+	want := []string{"VP8X", "ICCP", "ANIM", "ANMF", "ANMF", "EXIF", "XMP "}
+	if len(order) != len(want) {
+		t.Fatalf("chunks %q, want %q", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("chunks %q, want %q", order, want)
+		}
+	}
+
+	flags := chunks[0].Data[0]
+	if wantFlags := byte(webpFlagAnimation | webpFlagICC | webpFlagEXIF | webpFlagXMP | webpFlagAlpha); flags != wantFlags {
+		t.Errorf("VP8X flags %#x, want %#x", flags, wantFlags)
+	}
+	if frames, err := webpFrames(data); err != nil || len(frames) != 2 {
+		t.Errorf("webpFrames returned %d frames, %v", len(frames), err)
+	}
+}
+
+func TestConvertImageAnimatedGIF(t *testing.T) {
+	pages, format, err := ConvertImage(bytes.NewReader(gifData(t, 3, 0)), "anim.gif", DefaultOptions())
+	if err != nil {
+		t.Fatalf("ConvertImage: %v", err)
+	}
+	if format != "Animated GIF" || len(pages) != 1 {
+		t.Fatalf("got %d pages of format %q", len(pages), format)
+	}
+	if pages[0].Name != "anim.webp" || pages[0].Format != "WebP" || pages[0].Width != 24 || pages[0].Height != 16 {
+		t.Errorf("page is %s (%s, %dx%d)", pages[0].Name, pages[0].Format, pages[0].Width, pages[0].Height)
+	}
+	if frames, err := webpFrames(pages[0].Data); err != nil || len(frames) != 3 {
+		t.Errorf("page has %d frames, %v", len(frames), err)
+	}
+}
+
+// pngEncoder is an output format without animation support, for tests
+type pngEncoder struct{}
+
+func (pngEncoder) Name() string             { return "PNG" }
+func (pngEncoder) Extension() string        { return ".png" }
+func (pngEncoder) Available() error         { return nil }
+func (pngEncoder) Verify(data []byte) error { return nil }
+
+func (pngEncoder) Encode(img image.Image, quality float32) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func TestConvertImageAnimatedGIFStill(t *testing.T) {
+	Encoders = append(Encoders, pngEncoder{})
+	defer func() { Encoders = Encoders[:len(Encoders)-1] }()
+
+	opts := DefaultOptions()
+	opts.OutputFormat = "PNG"
+	pages, format, err := ConvertImage(bytes.NewReader(gifData(t, 3, 0)), "anim.gif", opts)
+	if err != nil {
+		t.Fatalf("ConvertImage: %v", err)
+	}
+	if format != FormatGIF || len(pages) != 1 || pages[0].Name != "anim.png" || pages[0].Format != "PNG" {
+		t.Fatalf("got %d pages of format %q", len(pages), format)
+	}
+	img, err := png.Decode(bytes.NewReader(pages[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first frame is palette color 0, black
+	if r, g, b, _ := img.At(3, 3).RGBA(); r != 0 || g != 0 || b != 0 {
+		t.Errorf("page is not the first frame")
+	}
+}
@@ -0,0 +1,108 @@
+package fileops
+
+import (
+	"fmt"
+	"io"
+)
+
+// Page is an encoded output page ready to be stored in an archive
+type Page struct {
+	Name       string // Archive path of the page, including the extension
+	Format     string // Output image format, e.g. "WebP"
+	Data       []byte
+	Width      int
+	Height     int
+	DoublePage bool // The page is a double-page spread that was not split
+}
+
+// ConvertImage decodes the image read from r, applies opts and encodes the
+// resulting pages with the encoder selected in opts. name is the archive path
+// of the source file and is used to name the pages. The source format is
+// returned for logging. A decoder or encoder panicking on a malformed image
+// is reported as a corrupt image.
+func ConvertImage(r io.Reader, name string, opts Options) (pages []Page, format string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			pages, err = nil, &DecodeError{Format: format, Kind: ErrCorruptImage, Err: fmt.Errorf("%v", v)}
+		}
+	}()
+
+	encoder, err := FindEncoder(opts.OutputFormat)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Animated GIFs become animated WebPs, as WebP is the only output format
+	// with animation support here. Other encoders get a still page of the
+	// first frame.
+	_, isWebP := encoder.(webpEncoder)
+	d, err := decode(r, isWebP)
+	format = d.format
+	if err != nil {
+		return nil, format, err
+	}
+
+	// Only WebP pages can carry an embedded profile or metadata; the pixels
+	// are already upright, so the orientation is reset
+	var meta imageMetadata
+	if opts.Metadata == MetadataKeep && isWebP {
+		if d.meta.exif != nil {
+			meta.exif = resetExifOrientation(d.meta.exif)
+		}
+		if d.meta.xmp != nil {
+			meta.xmp = resetXMPOrientation(d.meta.xmp)
+		}
+	}
+
+	if d.anim != nil {
+		anim := &Animation{Delays: d.anim.Delays, LoopCount: d.anim.LoopCount}
+		for _, frame := range d.anim.Frames {
+			frame, meta.iccProfile = applyColorProfile(frame, d.meta.iccProfile, opts.ColorProfile, true)
+			anim.Frames = append(anim.Frames, frame)
+		}
+		anim = ProcessAnimation(anim, opts)
+		data, err := encodeAnimatedWebP(anim, opts.Quality, meta)
+		if err != nil {
+			return nil, format, err
+		}
+		bounds := anim.Frames[0].Bounds()
+		return []Page{{
+			Name:       PageName(name, 0, 1) + encoder.Extension(),
+			Format:     encoder.Name(),
+			Data:       data,
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+			DoublePage: IsSpread(anim.Frames[0]),
+		}}, "Animated GIF", nil
+	}
+
+	// Profiles are converted before processing so grayscale detection sees
+	// the real colors
+	img, profile := applyColorProfile(d.img, d.meta.iccProfile, opts.ColorProfile, isWebP)
+	meta.iccProfile = profile
+
+	images := ProcessImage(img, opts)
+	pages = make([]Page, len(images))
+	for i, page := range images {
+		data, err := encoder.Encode(page, opts.Quality)
+		if err != nil {
+			return nil, format, err
+		}
+		bounds := page.Bounds()
+		if meta.iccProfile != nil || meta.exif != nil || meta.xmp != nil {
+			if data, err = embedMetadata(data, bounds.Dx(), bounds.Dy(), meta); err != nil {
+				return nil, format, err
+			}
+		}
+		pages[i] = Page{
+			Name:       PageName(name, i, len(images)) + encoder.Extension(),
+			Format:     encoder.Name(),
+			Data:       data,
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+			DoublePage: len(images) == 1 && IsSpread(page),
+		}
+	}
+
+	return pages, format, nil
+}
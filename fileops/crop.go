@@ -0,0 +1,122 @@
+package fileops
+
+import (
+	"image"
+	"image/color"
+)
+
+// cropLineRatio is the share of a row or column that must match the margin
+// color for the line to count as margin. The rest absorbs dust and scan noise.
+const cropLineRatio = 0.995
+
+// CropMargins trims uniform borders (typically white or black scan margins)
+// from img. A line belongs to the margin when its pixels are within tolerance
+// of the outermost line's color. At most maxPercent of the width and of the
+// height is ever removed, so blank or low-contrast pages are left mostly intact.
+func CropMargins(img image.Image, tolerance int, maxPercent float64) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() < 3 || bounds.Dy() < 3 || maxPercent <= 0 {
+		return img
+	}
+
+	rows := func(y int) scanLine {
+		return scanLine{start: image.Pt(bounds.Min.X, y), step: image.Pt(1, 0), length: bounds.Dx()}
+	}
+	columns := func(x int) scanLine {
+		return scanLine{start: image.Pt(x, bounds.Min.Y), step: image.Pt(0, 1), length: bounds.Dy()}
+	}
+
+	top := marginDepth(img, tolerance, bounds.Dy(), func(i int) scanLine { return rows(bounds.Min.Y + i) })
+	bottom := marginDepth(img, tolerance, bounds.Dy(), func(i int) scanLine { return rows(bounds.Max.Y - 1 - i) })
+	left := marginDepth(img, tolerance, bounds.Dx(), func(i int) scanLine { return columns(bounds.Min.X + i) })
+	right := marginDepth(img, tolerance, bounds.Dx(), func(i int) scanLine { return columns(bounds.Max.X - 1 - i) })
+
+	left, right = limitCrop(left, right, bounds.Dx(), maxPercent)
+	top, bottom = limitCrop(top, bottom, bounds.Dy(), maxPercent)
+	if left+right+top+bottom == 0 {
+		return img
+	}
+
+	return subImage(img, image.Rect(bounds.Min.X+left, bounds.Min.Y+top, bounds.Max.X-right, bounds.Max.Y-bottom))
+}
+
+// scanLine describes a row or column of pixels
+type scanLine struct {
+	start  image.Point
+	step   image.Point
+	length int
+}
+
+// marginDepth counts how many consecutive lines, starting at the edge, match
+// the color of the outermost line. line(i) returns the i-th line from the edge.
+func marginDepth(img image.Image, tolerance, lines int, line func(i int) scanLine) int {
+	reference, ok := uniformLineColor(img, tolerance, line(0))
+	if !ok {
+		return 0
+	}
+
+	depth := 1
+	for depth < lines-1 && lineMatches(img, reference, tolerance, line(depth)) {
+		depth++
+	}
+	return depth
+}
+
+// uniformLineColor returns the average color of a line if the line is uniform
+func uniformLineColor(img image.Image, tolerance int, line scanLine) (color.RGBA, bool) {
+	var sumR, sumG, sumB int
+	p := line.start
+	for i := 0; i < line.length; i++ {
+		r, g, b := rgb8(img.At(p.X, p.Y))
+		sumR, sumG, sumB = sumR+r, sumG+g, sumB+b
+		p = p.Add(line.step)
+	}
+
+	reference := color.RGBA{
+		R: uint8(sumR / line.length),
+		G: uint8(sumG / line.length),
+		B: uint8(sumB / line.length),
+		A: 0xff,
+	}
+	return reference, lineMatches(img, reference, tolerance, line)
+}
+
+// lineMatches reports whether nearly all pixels of a line are within
+// tolerance of the reference color
+func lineMatches(img image.Image, reference color.RGBA, tolerance int, line scanLine) bool {
+	allowed := int(float64(line.length) * (1 - cropLineRatio))
+	mismatches := 0
+
+	p := line.start
+	for i := 0; i < line.length; i++ {
+		r, g, b := rgb8(img.At(p.X, p.Y))
+		if abs(r-int(reference.R)) > tolerance || abs(g-int(reference.G)) > tolerance || abs(b-int(reference.B)) > tolerance {
+			mismatches++
+			if mismatches > allowed {
+				return false
+			}
+		}
+		p = p.Add(line.step)
+	}
+
+	return true
+}
+
+// limitCrop scales the margins removed from two opposite sides down so that
+// together they stay within maxPercent of size
+func limitCrop(first, second, size int, maxPercent float64) (int, int) {
+	limit := int(float64(size) * maxPercent / 100)
+	total := first + second
+	if total <= limit {
+		return first, second
+	}
+
+	first = first * limit / total
+	return first, limit - first
+}
+
+// rgb8 returns the 8-bit color channels of c
+func rgb8(c color.Color) (int, int, int) {
+	r, g, b, _ := c.RGBA()
+	return int(r >> 8), int(g >> 8), int(b >> 8)
+}
@@ -0,0 +1,88 @@
+package fileops
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// marginImage returns a white page with a black content box
+func marginImage(width, height int, content image.Rectangle) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if image.Pt(x, y).In(content) {
+				c = color.RGBA{0, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// checkerImage returns a page without margins
+func checkerImage(width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{uint8((x + y) % 2 * 255)})
+		}
+	}
+	return img
+}
+
+func TestLimitCrop(t *testing.T) {
+	tests := []struct {
+		first, second int
+		size          int
+		maxPercent    float64
+		wantFirst     int
+		wantSecond    int
+	}{
+		{10, 10, 100, 20, 10, 10},      // Exactly at the limit
+		{5, 0, 100, 20, 5, 0},          // Below the limit
+		{30, 10, 100, 20, 15, 5},       // Scaled down, keeping the proportion
+		{40, 0, 100, 20, 20, 0},        // One side only
+		{50, 50, 100, 10, 5, 5},        // Blank page
+		{99, 1, 1000, 5, 49, 1},        // Rounding goes to the second side
+		{10, 10, 100, 0, 0, 0},         // Cropping off
+		{0, 0, 100, 20, 0, 0},          // No margins
+		{60, 60, 200, 100, 60, 60},     // No real limit
+		{150, 150, 200, 100, 100, 100}, // Never more than the whole size
+	}
+
+	for _, tt := range tests {
+		first, second := limitCrop(tt.first, tt.second, tt.size, tt.maxPercent)
+		if first != tt.wantFirst || second != tt.wantSecond {
+			t.Errorf("limitCrop(%d, %d, %d, %v) = %d, %d, want %d, %d",
+				tt.first, tt.second, tt.size, tt.maxPercent, first, second, tt.wantFirst, tt.wantSecond)
+		}
+	}
+}
+
+func TestCropMargins(t *testing.T) {
+	tests := []struct {
+		name       string
+		img        image.Image
+		maxPercent float64
+		want       image.Rectangle
+	}{
+		{"margins", marginImage(100, 200, image.Rect(10, 20, 90, 180)), 50, image.Rect(10, 20, 90, 180)},
+		{"limited", marginImage(100, 200, image.Rect(30, 60, 70, 140)), 20, image.Rect(10, 20, 90, 180)},
+		{"uneven limit", marginImage(100, 100, image.Rect(30, 0, 90, 100)), 20, image.Rect(15, 0, 95, 100)},
+		{"no margins", checkerImage(50, 50), 50, image.Rect(0, 0, 50, 50)},
+		{"blank page", marginImage(100, 100, image.Rect(0, 0, 0, 0)), 10, image.Rect(5, 5, 95, 95)},
+		{"disabled", marginImage(100, 200, image.Rect(10, 20, 90, 180)), 0, image.Rect(0, 0, 100, 200)},
+		{"tiny", marginImage(2, 2, image.Rect(0, 0, 1, 1)), 50, image.Rect(0, 0, 2, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CropMargins(tt.img, 16, tt.maxPercent).Bounds()
+			if got != tt.want {
+				t.Errorf("CropMargins kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package fileops
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	xwebp "golang.org/x/image/webp"
+)
+
+// Decode error kinds, matched with errors.Is
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrTruncatedImage   = errors.New("truncated image")
+	ErrCorruptImage     = errors.New("corrupt image")
+)
+
+// DecodeError describes why an image could not be decoded
+type DecodeError struct {
+	Format string // Detected format, empty if the format is not supported
+	Kind   error  // ErrUnsupportedImage, ErrTruncatedImage or ErrCorruptImage
+	Err    error  // Underlying decoder error, if any
+}
+
+func (e *DecodeError) Error() string {
+	msg := e.Kind.Error()
+	if e.Format != "" {
+		msg = e.Format + ": " + msg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// headerPeekLen is how much of the stream is buffered before decoding. It
+// covers the sniffed magic bytes and the metadata (EXIF data, ICC profiles)
+// stored ahead of the image data.
+const headerPeekLen = 128 << 10
+
+// DecodeImage identifies the image format from the stream header and decodes
+// it once with the matching decoder. Animated GIFs return their first frame.
+// Failures are reported as *DecodeError.
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	d, err := decode(r, false)
+	return d.img, d.format, err
+}
+
+// decoded is an image read by decode, with the metadata needed for its output
+type decoded struct {
+	img    image.Image
+	anim   *Animation // Set instead of img for animated GIFs, if requested
+	format string
+	meta   imageMetadata
+}
+
+// decode is DecodeImage, optionally returning multi-frame GIFs as an Animation
+// instead of a still image. The format is set even when decoding fails.
+func decode(r io.Reader, animations bool) (decoded, error) {
+	input := &eofReader{r: r}
+	br := bufio.NewReaderSize(input, headerPeekLen)
+
+	header, _ := br.Peek(sniffLen)
+	d := decoded{format: SniffFormat(header)}
+	if d.format == "" {
+		kind := ErrUnsupportedImage
+		if len(header) == 0 {
+			kind = ErrTruncatedImage
+		}
+		return d, &DecodeError{Kind: kind}
+	}
+
+	// Metadata is lost once the image is re-encoded, so read it from the
+	// buffered header before the decoder consumes the stream. WebP files
+	// store EXIF and XMP after the image data, so they are read whole.
+	var src io.Reader = br
+	head, _ := br.Peek(headerPeekLen)
+	if d.format == FormatWebP {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return d, classifyDecodeError(d.format, err, true)
+		}
+		head, src = data, bytes.NewReader(data)
+	}
+	d.meta = readMetadata(d.format, head)
+
+	var err error
+	switch d.format {
+	case FormatJPEG:
+		d.img, err = jpeg.Decode(br)
+	case FormatPNG:
+		d.img, err = png.Decode(br)
+	case FormatGIF:
+		var anim *Animation
+		if anim, err = DecodeAnimation(br); err == nil {
+			if len(anim.Frames) == 0 {
+				return d, &DecodeError{Format: d.format, Kind: ErrCorruptImage}
+			}
+			if animations && len(anim.Frames) > 1 {
+				d.anim = anim
+				return d, nil
+			}
+			d.img = anim.Frames[0]
+		}
+	case FormatBMP:
+		d.img, err = bmp.Decode(br)
+	case FormatTIFF:
+		d.img, err = tiff.Decode(br)
+	case FormatWebP:
+		// The WebP decoder reads still images only
+		if len(head) > 20 && string(head[12:16]) == "VP8X" && head[20]&webpFlagAnimation != 0 {
+			return d, &DecodeError{Format: d.format, Kind: ErrUnsupportedImage, Err: errors.New("animated WebP")}
+		}
+		d.img, err = xwebp.Decode(src)
+	}
+	if err != nil {
+		d.img = nil
+		return d, classifyDecodeError(d.format, err, input.eof && br.Buffered() == 0)
+	}
+
+	// WebP pages carry no orientation, so turn the pixels upright instead
+	d.img = ApplyOrientation(d.img, exifOrientation(d.meta.exif))
+
+	return d, nil
+}
+
+// classifyDecodeError wraps a decoder error in a DecodeError of the right
+// kind. Decoders report running out of data inconsistently (the JPEG decoder
+// calls it "short Huffman data", the WebP decoder "short chunk data", the PNG
+// and GIF decoders "not enough pixel data" and "not enough image data"), so
+// such an error after the whole input was consumed counts as truncation too.
+// Small files are read whole up front, so exhaustion alone proves nothing.
+func classifyDecodeError(format string, err error, inputExhausted bool) error {
+	kind := ErrCorruptImage
+
+	var jpegUnsupported jpeg.UnsupportedError
+	var pngUnsupported png.UnsupportedError
+	var tiffUnsupported tiff.UnsupportedError
+	msg := err.Error()
+	switch {
+	case errors.As(err, &jpegUnsupported), errors.As(err, &pngUnsupported), errors.As(err, &tiffUnsupported),
+		errors.Is(err, bmp.ErrUnsupported):
+		kind = ErrUnsupportedImage
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		kind = ErrTruncatedImage
+	case inputExhausted && (strings.Contains(msg, "short") || strings.Contains(msg, "not enough")):
+		kind = ErrTruncatedImage
+	case strings.Contains(msg, "not implemented"), strings.Contains(msg, "not supported"), strings.Contains(msg, "unsupported"):
+		kind = ErrUnsupportedImage
+	}
+
+	return &DecodeError{Format: format, Kind: kind, Err: err}
+}
+
+// eofReader records whether the underlying reader reached the end of its data
+type eofReader struct {
+	r   io.Reader
+	eof bool
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		e.eof = true
+	}
+	return n, err
+}
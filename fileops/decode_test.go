@@ -0,0 +1,145 @@
+package fileops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// testPicture returns a small image with some detail
+func testPicture() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 48, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 48; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 5), uint8(y * 7), uint8(x ^ y), 255})
+		}
+	}
+	return img
+}
+
+// encodedPicture encodes testPicture with an encoder of the image packages
+func encodedPicture(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, testPicture()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// damaged returns data with everything after keep replaced by filler bytes
+func damaged(data []byte, keep int) []byte {
+	out := append([]byte{}, data[:keep]...)
+	return append(out, bytes.Repeat([]byte{0xAA}, len(data)-keep)...)
+}
+
+func TestClassifyDecodeError(t *testing.T) {
+	tests := []struct {
+		err       error
+		exhausted bool
+		want      error
+	}{
+		{io.ErrUnexpectedEOF, false, ErrTruncatedImage},
+		{io.EOF, false, ErrTruncatedImage},
+		{fmt.Errorf("reading chunk: %w", io.ErrUnexpectedEOF), false, ErrTruncatedImage},
+		{errors.New("invalid JPEG format: short Huffman data"), true, ErrTruncatedImage},
+		{errors.New("invalid JPEG format: short Huffman data"), false, ErrCorruptImage},
+		{jpeg.UnsupportedError("SOF type"), false, ErrUnsupportedImage},
+		{png.UnsupportedError("interlace method"), true, ErrUnsupportedImage},
+		{tiff.UnsupportedError("compression value 7"), false, ErrUnsupportedImage},
+		{bmp.ErrUnsupported, false, ErrUnsupportedImage},
+		{errors.New("webp: unsupported feature"), false, ErrUnsupportedImage},
+		{errors.New("lossy compression not implemented"), false, ErrUnsupportedImage},
+		{errors.New("png: invalid format: not enough pixel data"), true, ErrTruncatedImage},
+		{errors.New("gif: not enough image data"), false, ErrCorruptImage},
+		{errors.New("png: invalid format: invalid checksum"), true, ErrCorruptImage},
+		{errors.New("anything else"), false, ErrCorruptImage},
+	}
+
+	for _, tt := range tests {
+		err := classifyDecodeError(FormatJPEG, tt.err, tt.exhausted)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Format != FormatJPEG {
+			t.Fatalf("classifyDecodeError(%q) = %v, want a JPEG DecodeError", tt.err, err)
+		}
+		if !errors.Is(err, tt.want) || !errors.Is(err, tt.err) {
+			t.Errorf("classifyDecodeError(%q, %v) = %v, want %v", tt.err, tt.exhausted, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeImageErrors(t *testing.T) {
+	jpegPage := encodedPicture(t, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) })
+	pngPage := encodedPicture(t, png.Encode)
+	gifPage := encodedPicture(t, func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) })
+	bmpPage := encodedPicture(t, bmp.Encode)
+	tiffPage := encodedPicture(t, func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) })
+
+	// Arithmetic coding (SOF9) is not supported by the JPEG decoder
+	arithmetic := bytes.Replace(jpegPage, []byte{0xFF, 0xC0}, []byte{0xFF, 0xC9}, 1)
+
+	anim := &Animation{
+		Frames: []image.Image{testPicture(), image.NewRGBA(image.Rect(0, 0, 48, 32))},
+		Delays: []int{100, 100},
+	}
+	animatedWebP, err := EncodeAnimatedWebP(anim, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		want   error // Nil when the image decodes
+	}{
+		{"JPEG", jpegPage, FormatJPEG, nil},
+		{"PNG", pngPage, FormatPNG, nil},
+		{"GIF", gifPage, FormatGIF, nil},
+		{"BMP", bmpPage, FormatBMP, nil},
+		{"TIFF", tiffPage, FormatTIFF, nil},
+		{"truncated JPEG", jpegPage[:len(jpegPage)/2], FormatJPEG, ErrTruncatedImage},
+		{"truncated PNG", pngPage[:len(pngPage)/2], FormatPNG, ErrTruncatedImage},
+		{"truncated GIF", gifPage[:len(gifPage)/2], FormatGIF, ErrTruncatedImage},
+		{"truncated BMP", bmpPage[:len(bmpPage)/2], FormatBMP, ErrTruncatedImage},
+		{"truncated animated WebP", animatedWebP[:len(animatedWebP)/2], FormatWebP, ErrUnsupportedImage},
+		{"corrupt JPEG", damaged(jpegPage, 200), FormatJPEG, ErrCorruptImage},
+		{"corrupt PNG", damaged(pngPage, 60), FormatPNG, ErrCorruptImage},
+		{"arithmetic JPEG", arithmetic, FormatJPEG, ErrUnsupportedImage},
+		{"animated WebP", animatedWebP, FormatWebP, ErrUnsupportedImage},
+		{"empty", nil, "", ErrTruncatedImage},
+		{"text", []byte("not an image at all"), "", ErrUnsupportedImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, format, err := DecodeImage(bytes.NewReader(tt.data))
+			if format != tt.format {
+				t.Errorf("format %q, want %q", format, tt.format)
+			}
+			if tt.want == nil {
+				if err != nil || img.Bounds() != image.Rect(0, 0, 48, 32) {
+					t.Errorf("DecodeImage: %v", err)
+				}
+				return
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || !errors.Is(err, tt.want) {
+				t.Errorf("DecodeImage error %v, want %v", err, tt.want)
+			}
+			if img != nil {
+				t.Errorf("DecodeImage returned an image with error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package fileops
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+// Encoder writes processed pages in an output image format
+type Encoder interface {
+	Name() string      // Display name, e.g. "WebP"
+	Extension() string // File extension including the dot, e.g. ".webp"
+	Available() error  // Reports why the encoder cannot be used, if it cannot
+	Encode(img image.Image, quality float32) ([]byte, error)
+	Verify(data []byte) error // Decodes an encoded page, reporting why it is unreadable
+}
+
+// Encoders lists the available output image formats. The first entry is the default.
+var Encoders = []Encoder{
+	webpEncoder{},
+	commandEncoder{
+		name:           "AVIF",
+		extension:      ".avif",
+		command:        "avifenc",
+		install:        "Please install libavif (avifenc)",
+		decoder:        "avifdec",
+		decoderInstall: "Please install libavif (avifdec)",
+		args: func(quality float32, input, output string) []string {
+			return []string{"-q", formatQuality(quality), input, output}
+		},
+	},
+	commandEncoder{
+		name:           "JXL",
+		extension:      ".jxl",
+		command:        "cjxl",
+		install:        "Please install libjxl (cjxl)",
+		decoder:        "djxl",
+		decoderInstall: "Please install libjxl (djxl)",
+		args: func(quality float32, input, output string) []string {
+			return []string{input, output, "-q", formatQuality(quality)}
+		},
+	},
+}
+
+// FindEncoder looks up an encoder by name, ignoring case. An empty name
+// selects the default (WebP) encoder.
+func FindEncoder(name string) (Encoder, error) {
+	if name == "" {
+		return Encoders[0], nil
+	}
+	for _, encoder := range Encoders {
+		if strings.EqualFold(encoder.Name(), name) {
+			return encoder, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown image format '%s' (available: %s)", name, strings.Join(EncoderNames(), ", "))
+}
+
+// EncoderNames returns the names of all encoders
+func EncoderNames() []string {
+	names := make([]string, len(Encoders))
+	for i, encoder := range Encoders {
+		names[i] = encoder.Name()
+	}
+	return names
+}
+
+// FindEncoderByExtension looks up the encoder writing files with the given
+// extension, ignoring case
+func FindEncoderByExtension(extension string) (Encoder, error) {
+	for _, encoder := range Encoders {
+		if strings.EqualFold(encoder.Extension(), extension) {
+			return encoder, nil
+		}
+	}
+	return nil, fmt.Errorf("no image format uses the extension '%s'", extension)
+}
+
+// VerifyPage decodes a page stored under name, picking the decoder from its
+// extension
+func VerifyPage(name string, data []byte) error {
+	encoder, err := FindEncoderByExtension(filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	return encoder.Verify(data)
+}
+
+// VerifyImage decodes an image stored under name: a page written by one of
+// the encoders, or an image stored unchanged
+func VerifyImage(name string, data []byte) error {
+	if _, err := FindEncoderByExtension(filepath.Ext(name)); err == nil {
+		return VerifyPage(name, data)
+	}
+	_, _, err := DecodeImage(bytes.NewReader(data))
+	return err
+}
+
+// webpEncoder encodes pages as WebP
+type webpEncoder struct{}
+
+func (webpEncoder) Name() string      { return "WebP" }
+func (webpEncoder) Extension() string { return ".webp" }
+func (webpEncoder) Available() error  { return nil }
+
+func (webpEncoder) Encode(img image.Image, quality float32) ([]byte, error) {
+	return ConvertToWebP(img, quality)
+}
+
+// Verify decodes the page, or every frame of an animated page
+func (webpEncoder) Verify(data []byte) error {
+	frames, err := webpFrames(data)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if _, err := xwebp.Decode(bytes.NewReader(frame)); err != nil {
+			return classifyDecodeError(FormatWebP, err, false)
+		}
+	}
+	return nil
+}
+
+// commandEncoder encodes pages by running an external encoder on a
+// temporary lossless PNG, the same way RAR and 7Z archives are created.
+// Pages are verified by decoding them back to PNG with the matching decoder.
+type commandEncoder struct {
+	name           string
+	extension      string
+	command        string
+	install        string
+	args           func(quality float32, input, output string) []string
+	decoder        string
+	decoderInstall string
+}
+
+func (c commandEncoder) Name() string      { return c.name }
+func (c commandEncoder) Extension() string { return c.extension }
+
+func (c commandEncoder) Available() error {
+	if _, err := exec.LookPath(c.command); err != nil {
+		return fmt.Errorf("%s command not found. %s", c.command, c.install)
+	}
+	return nil
+}
+
+func (c commandEncoder) Encode(img image.Image, quality float32) ([]byte, error) {
+	if err := c.Available(); err != nil {
+		return nil, err
+	}
+
+	tempDir, err := os.MkdirTemp("", "cbz-encode-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	input := filepath.Join(tempDir, "page.png")
+	output := filepath.Join(tempDir, "page"+c.extension)
+
+	file, err := os.Create(input)
+	if err != nil {
+		return nil, err
+	}
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(file, img); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(c.command, c.args(quality, input, output)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %v: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+
+	return os.ReadFile(output)
+}
+
+func (c commandEncoder) Verify(data []byte) error {
+	if _, err := exec.LookPath(c.decoder); err != nil {
+		return fmt.Errorf("%s command not found. %s", c.decoder, c.decoderInstall)
+	}
+
+	tempDir, err := os.MkdirTemp("", "cbz-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	input := filepath.Join(tempDir, "page"+c.extension)
+	output := filepath.Join(tempDir, "page.png")
+	if err := os.WriteFile(input, data, 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(c.decoder, input, output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to decode %s: %v: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = png.Decode(file)
+	return err
+}
+
+// formatQuality formats a 0-100 quality value for an encoder command line
+func formatQuality(quality float32) string {
+	return strconv.Itoa(int(quality + 0.5))
+}
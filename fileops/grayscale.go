@@ -0,0 +1,127 @@
+package fileops
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// grayOutlierRatio is the share of pixels allowed to exceed the tolerance. It
+// absorbs JPEG chroma noise and small colored specks such as publisher logos.
+const grayOutlierRatio = 0.002
+
+// IsNearGrayscale reports whether img only contains (nearly) gray pixels, so
+// it can be stored as a single-channel image without visible loss
+func IsNearGrayscale(img image.Image, tolerance int) bool {
+	switch m := img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	case *image.YCbCr:
+		return isNearGrayYCbCr(m, tolerance)
+	case *image.RGBA:
+		return isNearGrayPix(m.Pix, m.Stride, m.Rect, tolerance)
+	case *image.NRGBA:
+		return isNearGrayPix(m.Pix, m.Stride, m.Rect, tolerance)
+	}
+
+	bounds := img.Bounds()
+	allowed := int(float64(bounds.Dx()*bounds.Dy()) * grayOutlierRatio)
+	outliers := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			if channelSpread(int(r>>8), int(g>>8), int(b>>8)) > tolerance {
+				outliers++
+				if outliers > allowed {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// isNearGrayYCbCr checks the chroma planes of a decoded JPEG directly. A
+// chroma offset of d from neutral moves the RGB channels apart by roughly 2d.
+func isNearGrayYCbCr(img *image.YCbCr, tolerance int) bool {
+	chromaTolerance := tolerance / 2
+	rect := img.Rect
+	allowed := int(float64(rect.Dx()*rect.Dy()) * grayOutlierRatio)
+	outliers := 0
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := img.COffset(x, y)
+			if abs(int(img.Cb[i])-128) > chromaTolerance || abs(int(img.Cr[i])-128) > chromaTolerance {
+				outliers++
+				if outliers > allowed {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// isNearGrayPix checks 4-byte-per-pixel RGBA or NRGBA pixel data
+func isNearGrayPix(pix []byte, stride int, rect image.Rectangle, tolerance int) bool {
+	allowed := int(float64(rect.Dx()*rect.Dy()) * grayOutlierRatio)
+	outliers := 0
+
+	for y := 0; y < rect.Dy(); y++ {
+		row := pix[y*stride : y*stride+rect.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			if row[i+3] == 0 {
+				continue
+			}
+			if channelSpread(int(row[i]), int(row[i+1]), int(row[i+2])) > tolerance {
+				outliers++
+				if outliers > allowed {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// channelSpread returns the largest difference between the color channels
+func channelSpread(r, g, b int) int {
+	return max(r, g, b) - min(r, g, b)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// isOpaque reports whether img has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// toGray converts img to an 8-bit grayscale image. Transparent areas are
+// flattened onto white, which is how e-ink readers display them.
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Over)
+	return gray
+}
@@ -0,0 +1,302 @@
+package fileops
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ColorProfileMode controls what happens to ICC color profiles embedded in
+// the source images
+type ColorProfileMode int
+
+const (
+	ColorProfileSRGB  ColorProfileMode = iota // Convert the pixels to sRGB and drop the profile
+	ColorProfileEmbed                         // Keep the pixels and embed the profile in WebP pages
+)
+
+// String returns the name of the color profile mode
+func (c ColorProfileMode) String() string {
+	if c == ColorProfileEmbed {
+		return "embed"
+	}
+	return "srgb"
+}
+
+// ParseColorProfileMode parses a color profile mode name (srgb or embed)
+func ParseColorProfileMode(name string) (ColorProfileMode, error) {
+	switch strings.ToLower(name) {
+	case "srgb":
+		return ColorProfileSRGB, nil
+	case "embed":
+		return ColorProfileEmbed, nil
+	default:
+		return ColorProfileSRGB, fmt.Errorf("unknown color profile mode '%s' (use srgb or embed)", name)
+	}
+}
+
+// applyColorProfile handles the ICC profile of a decoded image according to
+// mode. It returns the image to process and the profile to embed in the
+// output, if any. Only RGB profiles can be embedded, and only when canEmbed
+// is set; profiles that can be neither converted nor embedded (such as CMYK
+// profiles, whose pixels the decoder already converted to RGB) are dropped.
+func applyColorProfile(img image.Image, profile []byte, mode ColorProfileMode, canEmbed bool) (image.Image, []byte) {
+	if len(profile) == 0 {
+		return img, nil
+	}
+
+	embeddable := canEmbed && len(profile) >= 20 && string(profile[16:20]) == "RGB "
+	if mode == ColorProfileEmbed && embeddable {
+		return img, profile
+	}
+	converted, err := ConvertToSRGB(img, profile)
+	if err == nil {
+		return converted, nil
+	}
+	if embeddable && !errors.Is(err, errMalformedICC) {
+		return img, profile
+	}
+	return img, nil
+}
+
+// errMalformedICC marks profiles too damaged to parse, which are dropped
+// rather than embedded
+var errMalformedICC = errors.New("malformed ICC profile")
+
+// srgbFromD50 converts D50-adapted XYZ, the ICC connection space, to linear sRGB
+var srgbFromD50 = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// iccProfile is the part of an ICC profile needed for conversion: the tone
+// curves of each channel and the matrix to the connection space
+type iccProfile struct {
+	gray   bool
+	curves [3][256]float64 // Linear value of each 8-bit channel value
+	matrix [3][3]float64   // Linear RGB to D50 XYZ, unused for gray profiles
+}
+
+// ConvertToSRGB converts img from the color space described by an ICC
+// profile to sRGB. Matrix/TRC RGB profiles (such as Adobe RGB and Display P3)
+// and gray profiles are supported; LUT-based profiles return an error. Images
+// whose profile is already equivalent to sRGB are returned unchanged.
+func ConvertToSRGB(img image.Image, profile []byte) (image.Image, error) {
+	p, err := parseICCProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combined transform from linear source RGB to linear sRGB
+	transform := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if !p.gray {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				transform[i][j] = 0
+				for k := 0; k < 3; k++ {
+					transform[i][j] += srgbFromD50[i][k] * p.matrix[k][j]
+				}
+			}
+		}
+	}
+	if isSRGBEquivalent(p, transform) {
+		return img, nil
+	}
+
+	var encode [4096]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(srgbEncode(float64(i)/4095) * 255))
+	}
+	toSRGB := func(v float64) uint8 {
+		return encode[int(math.Round(max(0, min(1, v))*4095))]
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		r := p.curves[0][dst.Pix[i]]
+		g := p.curves[1][dst.Pix[i+1]]
+		b := p.curves[2][dst.Pix[i+2]]
+		dst.Pix[i] = toSRGB(transform[0][0]*r + transform[0][1]*g + transform[0][2]*b)
+		dst.Pix[i+1] = toSRGB(transform[1][0]*r + transform[1][1]*g + transform[1][2]*b)
+		dst.Pix[i+2] = toSRGB(transform[2][0]*r + transform[2][1]*g + transform[2][2]*b)
+	}
+
+	if _, ok := img.(*image.Gray); ok {
+		return toGray(dst), nil
+	}
+	return dst, nil
+}
+
+// isSRGBEquivalent reports whether converting with the profile and transform
+// would leave every 8-bit value unchanged
+func isSRGBEquivalent(p *iccProfile, transform [3][3]float64) bool {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			identity := 0.0
+			if i == j {
+				identity = 1
+			}
+			if math.Abs(transform[i][j]-identity) > 0.01 {
+				return false
+			}
+		}
+	}
+	for c := 0; c < 3; c++ {
+		for v, linear := range p.curves[c] {
+			if int(math.Round(srgbEncode(linear)*255)) != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// srgbEncode applies the sRGB transfer function to a linear value
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// parseICCProfile reads the tone curves and colorant matrix of an RGB or gray
+// display profile
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errMalformedICC
+	}
+	if string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("unsupported ICC connection space %q", data[20:24])
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			return nil, fmt.Errorf("%w: truncated tag table", errMalformedICC)
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("%w: truncated tag", errMalformedICC)
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	p := &iccProfile{}
+	switch string(data[16:20]) {
+	case "GRAY":
+		curve, err := parseICCCurve(tags["kTRC"])
+		if err != nil {
+			return nil, err
+		}
+		p.gray = true
+		p.curves = [3][256]float64{curve, curve, curve}
+	case "RGB ":
+		for c, name := range []string{"r", "g", "b"} {
+			curve, err := parseICCCurve(tags[name+"TRC"])
+			if err != nil {
+				return nil, err
+			}
+			p.curves[c] = curve
+
+			xyz := tags[name+"XYZ"]
+			if len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+				return nil, fmt.Errorf("ICC profile has no colorant matrix")
+			}
+			for i := 0; i < 3; i++ {
+				p.matrix[i][c] = s15Fixed16(xyz[8+i*4:])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ICC color space %q", data[16:20])
+	}
+
+	return p, nil
+}
+
+// parseICCCurve samples a curv or para tone curve at the 256 8-bit values
+func parseICCCurve(data []byte) ([256]float64, error) {
+	var curve [256]float64
+	if len(data) < 12 {
+		return curve, fmt.Errorf("ICC profile has no tone curve")
+	}
+
+	var f func(x float64) float64
+	switch string(data[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(data[8:]))
+		switch {
+		case n == 0:
+			f = func(x float64) float64 { return x }
+		case n == 1 && len(data) < 14:
+			return curve, fmt.Errorf("%w: truncated tone curve", errMalformedICC)
+		case n == 1:
+			gamma := float64(binary.BigEndian.Uint16(data[12:])) / 256
+			f = func(x float64) float64 { return math.Pow(x, gamma) }
+		case len(data) >= 12+2*n:
+			table := make([]float64, n)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(data[12+2*i:])) / 65535
+			}
+			f = func(x float64) float64 {
+				pos := x * float64(n-1)
+				i := min(int(pos), n-2)
+				return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+			}
+		default:
+			return curve, fmt.Errorf("%w: truncated tone curve", errMalformedICC)
+		}
+	case "para":
+		kind := int(binary.BigEndian.Uint16(data[8:]))
+		counts := []int{1, 3, 4, 5, 7}
+		if kind >= len(counts) || len(data) < 12+4*counts[kind] {
+			return curve, fmt.Errorf("unsupported ICC parametric curve")
+		}
+		// Y = (aX + b)^g + e for X >= d, cX + f below, with unused terms at their neutral values
+		g, a, b, c, d, e, fOffset := s15Fixed16(data[12:]), 1.0, 0.0, 0.0, 0.0, 0.0, 0.0
+		params := make([]float64, counts[kind])
+		for i := range params {
+			params[i] = s15Fixed16(data[12+4*i:])
+		}
+		switch kind {
+		case 1:
+			a, b = params[1], params[2]
+			d = -b / a
+		case 2:
+			a, b, e, fOffset = params[1], params[2], params[3], params[3]
+			d = -b / a
+		case 3:
+			a, b, c, d = params[1], params[2], params[3], params[4]
+		case 4:
+			a, b, c, d, e, fOffset = params[1], params[2], params[3], params[4], params[5], params[6]
+		}
+		f = func(x float64) float64 {
+			if x >= d {
+				return math.Pow(max(0, a*x+b), g) + e
+			}
+			return c*x + fOffset
+		}
+	default:
+		return curve, fmt.Errorf("unsupported ICC tone curve type %q", data[:4])
+	}
+
+	for i := range curve {
+		curve[i] = f(float64(i) / 255)
+	}
+	return curve, nil
+}
+
+// s15Fixed16 decodes a signed 15.16 fixed-point number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
@@ -0,0 +1,280 @@
+package fileops
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// s15 encodes a signed 15.16 fixed-point number
+func s15(v float64) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+}
+
+// curvTag returns a curv tone curve tag with the given 16-bit entries
+func curvTag(entries ...uint16) []byte {
+	data := append([]byte("curv\x00\x00\x00\x00"), binary.BigEndian.AppendUint32(nil, uint32(len(entries)))...)
+	for _, v := range entries {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return data
+}
+
+// gammaTag returns a curv tag holding a single gamma value
+func gammaTag(gamma float64) []byte {
+	return curvTag(uint16(math.Round(gamma * 256)))
+}
+
+// paraTag returns a parametric curve tag of the given function type
+func paraTag(kind uint16, params ...float64) []byte {
+	data := binary.BigEndian.AppendUint16([]byte("para\x00\x00\x00\x00"), kind)
+	data = append(data, 0, 0)
+	for _, v := range params {
+		data = append(data, s15(v)...)
+	}
+	return data
+}
+
+// xyzTag returns an XYZ tag holding one colorant
+func xyzTag(x, y, z float64) []byte {
+	data := []byte("XYZ \x00\x00\x00\x00")
+	for _, v := range []float64{x, y, z} {
+		data = append(data, s15(v)...)
+	}
+	return data
+}
+
+// srgbCurve is the sRGB transfer function as a parametric curve
+var srgbCurve = paraTag(3, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)
+
+// iccData builds a display profile of the given color space with tags
+func iccData(space string, tags map[string][]byte) []byte {
+	header := make([]byte, 128)
+	copy(header[12:], "mntr")
+	copy(header[16:], space)
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	// Tags in a fixed order, so profiles are reproducible
+	var names []string
+	for _, name := range []string{"rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC", "kTRC"} {
+		if tags[name] != nil {
+			names = append(names, name)
+		}
+	}
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(names)))
+	var body []byte
+	offset := 128 + 4 + 12*len(names)
+	for _, name := range names {
+		table = append(table, name...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(body)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tags[name])))
+		body = append(body, tags[name]...)
+	}
+	data := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+// rgbProfile builds a matrix/TRC profile from D50 colorants and one curve
+func rgbProfile(colorants [3][3]float64, curve []byte) []byte {
+	return iccData("RGB ", map[string][]byte{
+		"rXYZ": xyzTag(colorants[0][0], colorants[0][1], colorants[0][2]),
+		"gXYZ": xyzTag(colorants[1][0], colorants[1][1], colorants[1][2]),
+		"bXYZ": xyzTag(colorants[2][0], colorants[2][1], colorants[2][2]),
+		"rTRC": curve, "gTRC": curve, "bTRC": curve,
+	})
+}
+
+// D50-adapted colorants of common RGB spaces
+var (
+	srgbColorants     = [3][3]float64{{0.4361, 0.2225, 0.0139}, {0.3851, 0.7169, 0.0971}, {0.1431, 0.0606, 0.7141}}
+	adobeRGBColorants = [3][3]float64{{0.6097, 0.3111, 0.0195}, {0.2053, 0.6257, 0.0609}, {0.1492, 0.0632, 0.7446}}
+)
+
+func TestParseICCCurve(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		at128     float64 // Linear value of 8-bit 128
+		malformed bool    // Whether the curve is rejected as malformed
+		err       bool
+	}{
+		{"identity", curvTag(), 128.0 / 255, false, false},
+		{"gamma", gammaTag(2.2), math.Pow(128.0/255, 563.0/256), false, false},
+		{"table", curvTag(0, 65535), 128.0 / 255, false, false},
+		{"inverted table", curvTag(65535, 32768, 0), 1 - 128.0/255, false, false},
+		{"parametric gamma", paraTag(0, 1.8), math.Pow(128.0/255, 1.8), false, false},
+		{"parametric sRGB", srgbCurve, math.Pow((128.0/255+0.055)/1.055, 2.4), false, false},
+		{"truncated gamma", curvTag(1)[:13], 0, true, true},
+		{"truncated table", curvTag(1, 2, 3)[:16], 0, true, true},
+		{"short parametric", paraTag(3, 2.4, 1), 0, false, true},
+		{"unknown parametric", paraTag(9, 1), 0, false, true},
+		{"unknown type", []byte("mAB \x00\x00\x00\x00\x00\x00\x00\x00"), 0, false, true},
+		{"missing", nil, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curve, err := parseICCCurve(tt.data)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseICCCurve succeeded, want an error")
+				}
+				if errors.Is(err, errMalformedICC) != tt.malformed {
+					t.Errorf("parseICCCurve error %v, malformed %v", err, tt.malformed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseICCCurve: %v", err)
+			}
+			if math.Abs(curve[128]-tt.at128) > 0.002 {
+				t.Errorf("curve at 128 is %.4f, want %.4f", curve[128], tt.at128)
+			}
+		})
+	}
+}
+
+func TestParseICCProfileErrors(t *testing.T) {
+	valid := rgbProfile(srgbColorants, srgbCurve)
+	lut := iccData("RGB ", map[string][]byte{"rTRC": srgbCurve, "gTRC": srgbCurve, "bTRC": srgbCurve})
+	cmyk := iccData("CMYK", nil)
+
+	truncatedTable := append([]byte{}, valid[:140]...)
+	badOffset := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badOffset[132+4:], uint32(len(valid)))
+	noSignature := append([]byte{}, valid...)
+	copy(noSignature[36:], "xxxx")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		malformed bool
+	}{
+		{"LUT-based", lut, false},
+		{"CMYK", cmyk, false},
+		{"too short", valid[:100], true},
+		{"no signature", noSignature, true},
+		{"truncated tag table", truncatedTable, true},
+		{"tag past the end", badOffset, true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseICCProfile(tt.data)
+		if err == nil {
+			t.Errorf("%s: parseICCProfile succeeded, want an error", tt.name)
+		} else if errors.Is(err, errMalformedICC) != tt.malformed {
+			t.Errorf("%s: parseICCProfile error %v, malformed %v", tt.name, err, tt.malformed)
+		}
+	}
+}
+
+func TestConvertToSRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile []byte
+		in      color.NRGBA
+		want    color.NRGBA
+	}{
+		// Linear gray 128 is brighter once sRGB-encoded
+		{"linear", rgbProfile(srgbColorants, curvTag()), color.NRGBA{128, 128, 128, 255}, color.NRGBA{188, 188, 188, 255}},
+		// Neutral colors stay neutral across primaries
+		{"Adobe RGB gray", rgbProfile(adobeRGBColorants, gammaTag(2.2)), color.NRGBA{128, 128, 128, 255}, color.NRGBA{128, 128, 128, 255}},
+		{"Adobe RGB white", rgbProfile(adobeRGBColorants, gammaTag(2.2)), color.NRGBA{255, 255, 255, 255}, color.NRGBA{255, 255, 255, 255}},
+		// Adobe RGB green lies outside sRGB and is clipped
+		{"Adobe RGB green", rgbProfile(adobeRGBColorants, gammaTag(2.2)), color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 255, 0, 255}},
+		{"Adobe RGB red", rgbProfile(adobeRGBColorants, gammaTag(2.2)), color.NRGBA{255, 0, 0, 255}, color.NRGBA{255, 0, 0, 255}},
+		{"alpha kept", rgbProfile(srgbColorants, curvTag()), color.NRGBA{0, 0, 0, 100}, color.NRGBA{0, 0, 0, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+			img.SetNRGBA(1, 1, tt.in)
+			converted, err := ConvertToSRGB(img, tt.profile)
+			if err != nil {
+				t.Fatalf("ConvertToSRGB: %v", err)
+			}
+			got := converted.(*image.NRGBA).NRGBAAt(1, 1)
+			if diff(got.R, tt.want.R) > 1 || diff(got.G, tt.want.G) > 1 || diff(got.B, tt.want.B) > 1 || got.A != tt.want.A {
+				t.Errorf("converted %v to %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// diff returns the absolute difference of two channel values
+func diff(a, b uint8) int {
+	return abs(int(a) - int(b))
+}
+
+func TestConvertToSRGBUnchanged(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	converted, err := ConvertToSRGB(img, rgbProfile(srgbColorants, srgbCurve))
+	if err != nil {
+		t.Fatalf("ConvertToSRGB: %v", err)
+	}
+	if converted != image.Image(img) {
+		t.Errorf("an sRGB profile converted the image")
+	}
+}
+
+func TestConvertToSRGBGray(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.SetGray(0, 0, color.Gray{128})
+	converted, err := ConvertToSRGB(img, iccData("GRAY", map[string][]byte{"kTRC": curvTag()}))
+	if err != nil {
+		t.Fatalf("ConvertToSRGB: %v", err)
+	}
+	gray, ok := converted.(*image.Gray)
+	if !ok {
+		t.Fatalf("gray image converted to %T", converted)
+	}
+	if v := gray.GrayAt(0, 0).Y; diff(v, 188) > 1 {
+		t.Errorf("linear gray 128 converted to %d, want 188", v)
+	}
+}
+
+func TestApplyColorProfile(t *testing.T) {
+	adobeRGB := rgbProfile(adobeRGBColorants, gammaTag(2.2))
+	lut := iccData("RGB ", map[string][]byte{"rTRC": srgbCurve, "gTRC": srgbCurve, "bTRC": srgbCurve})
+	cmyk := iccData("CMYK", nil)
+	malformed := adobeRGB[:140]
+
+	tests := []struct {
+		name      string
+		profile   []byte
+		mode      ColorProfileMode
+		canEmbed  bool
+		converted bool
+		embedded  bool
+	}{
+		{"none", nil, ColorProfileSRGB, true, false, false},
+		{"convert", adobeRGB, ColorProfileSRGB, true, true, false},
+		{"embed", adobeRGB, ColorProfileEmbed, true, false, true},
+		{"embed unsupported by the format", adobeRGB, ColorProfileEmbed, false, true, false},
+		{"LUT-based kept", lut, ColorProfileSRGB, true, false, true},
+		{"LUT-based dropped", lut, ColorProfileSRGB, false, false, false},
+		{"CMYK", cmyk, ColorProfileEmbed, true, false, false},
+		{"malformed", malformed, ColorProfileSRGB, true, false, false},
+		{"malformed embed", malformed, ColorProfileEmbed, true, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+			img.SetNRGBA(0, 0, color.NRGBA{0, 200, 0, 255})
+			out, profile := applyColorProfile(img, tt.profile, tt.mode, tt.canEmbed)
+			if converted := out != image.Image(img); converted != tt.converted {
+				t.Errorf("converted %v, want %v", converted, tt.converted)
+			}
+			if embedded := profile != nil; embedded != tt.embedded {
+				t.Errorf("embedded %v, want %v", embedded, tt.embedded)
+			}
+		})
+	}
+}
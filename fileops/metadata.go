@@ -0,0 +1,247 @@
+package fileops
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// jpegSegment is a marker segment from the header of a JPEG stream
+type jpegSegment struct {
+	Marker byte
+	Data   []byte
+}
+
+// readJPEGSegments walks the JPEG marker segments up to the image data
+func readJPEGSegments(r *bufio.Reader) []jpegSegment {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return nil
+	}
+
+	var segments []jpegSegment
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return segments
+		}
+
+		// Padding bytes and markers without a length
+		if marker[1] == 0xFF || marker[1] == 0x01 || (marker[1] >= 0xD0 && marker[1] <= 0xD7) {
+			continue
+		}
+		// Start of scan or end of image: no metadata after the pixel data
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return segments
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return segments
+		}
+
+		data := make([]byte, length-2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return segments
+		}
+		segments = append(segments, jpegSegment{Marker: marker[1], Data: data})
+	}
+}
+
+// imageMetadata is the metadata read from the header of an image file
+type imageMetadata struct {
+	iccProfile []byte
+	exif       []byte // TIFF structure, without the JPEG "Exif\x00\x00" prefix
+	xmp        []byte
+}
+
+// MetadataMode controls whether EXIF and XMP metadata is carried into the output
+type MetadataMode int
+
+const (
+	MetadataStrip MetadataMode = iota // Drop EXIF and XMP data, including GPS positions
+	MetadataKeep                      // Store EXIF and XMP data in WebP pages
+)
+
+// String returns the name of the metadata mode
+func (m MetadataMode) String() string {
+	if m == MetadataKeep {
+		return "keep"
+	}
+	return "strip"
+}
+
+// ParseMetadataMode parses a metadata mode name (strip or keep)
+func ParseMetadataMode(name string) (MetadataMode, error) {
+	switch strings.ToLower(name) {
+	case "strip":
+		return MetadataStrip, nil
+	case "keep":
+		return MetadataKeep, nil
+	default:
+		return MetadataStrip, fmt.Errorf("unknown metadata mode '%s' (use strip or keep)", name)
+	}
+}
+
+// jpegXMPSignature starts the APP1 segment holding XMP data
+const jpegXMPSignature = "http://ns.adobe.com/xap/1.0/\x00"
+
+// readMetadata extracts the ICC profile, EXIF and XMP data from the start of
+// an image file, or all of it for WebP. JPEG profiles may be split over
+// several APP2 segments; PNG stores them compressed in the iCCP chunk.
+func readMetadata(format string, head []byte) imageMetadata {
+	var meta imageMetadata
+	switch format {
+	case FormatJPEG:
+		segments := readJPEGSegments(bufio.NewReader(bytes.NewReader(head)))
+		meta.iccProfile = readJPEGICCProfile(segments)
+		for _, segment := range segments {
+			switch {
+			case segment.Marker != 0xE1:
+			case meta.exif == nil && bytes.HasPrefix(segment.Data, []byte("Exif\x00\x00")):
+				meta.exif = segment.Data[6:]
+			case meta.xmp == nil && bytes.HasPrefix(segment.Data, []byte(jpegXMPSignature)):
+				meta.xmp = segment.Data[len(jpegXMPSignature):]
+			}
+		}
+	case FormatPNG:
+		meta = readPNGMetadata(head)
+	case FormatWebP:
+		meta.iccProfile = readWebPChunk(head, "ICCP")
+		meta.exif = bytes.TrimPrefix(readWebPChunk(head, "EXIF"), []byte("Exif\x00\x00"))
+		meta.xmp = readWebPChunk(head, "XMP ")
+	}
+	if len(meta.exif) == 0 {
+		meta.exif = nil
+	}
+	return meta
+}
+
+// readJPEGICCProfile reassembles an ICC profile from its numbered APP2 segments
+func readJPEGICCProfile(segments []jpegSegment) []byte {
+	const signature = "ICC_PROFILE\x00"
+
+	type part struct {
+		seq  int
+		data []byte
+	}
+	var parts []part
+	count := 0
+	for _, segment := range segments {
+		if segment.Marker != 0xE2 || len(segment.Data) < len(signature)+2 || !bytes.HasPrefix(segment.Data, []byte(signature)) {
+			continue
+		}
+		parts = append(parts, part{seq: int(segment.Data[len(signature)]), data: segment.Data[len(signature)+2:]})
+		count = int(segment.Data[len(signature)+1])
+	}
+	if len(parts) == 0 || len(parts) != count {
+		return nil
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].seq < parts[j].seq })
+	var profile []byte
+	for i, p := range parts {
+		if p.seq != i+1 {
+			return nil
+		}
+		profile = append(profile, p.data...)
+	}
+	return profile
+}
+
+// readPNGMetadata reads the iCCP, eXIf and XMP iTXt chunks, which must come
+// before the image data
+func readPNGMetadata(data []byte) imageMetadata {
+	var meta imageMetadata
+	for offset := 8; offset+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		start := offset + 8
+		if chunkType == "IDAT" || size < 0 || start+size > len(data) {
+			break
+		}
+		chunk := data[start : start+size]
+
+		switch chunkType {
+		case "iCCP":
+			// Profile name, null separator, compression method, zlib data
+			nul := bytes.IndexByte(chunk, 0)
+			if nul >= 0 && nul+2 <= len(chunk) && chunk[nul+1] == 0 {
+				meta.iccProfile = inflate(chunk[nul+2:])
+			}
+		case "eXIf":
+			meta.exif = chunk
+		case "iTXt":
+			// Keyword, null, compression flag and method, language tag, null,
+			// translated keyword, null, text
+			const keyword = "XML:com.adobe.xmp\x00"
+			if !bytes.HasPrefix(chunk, []byte(keyword)) || len(chunk) < len(keyword)+2 {
+				break
+			}
+			compressed := chunk[len(keyword)] == 1
+			fields := bytes.SplitN(chunk[len(keyword)+2:], []byte{0}, 3)
+			if len(fields) != 3 {
+				break
+			}
+			if compressed {
+				meta.xmp = inflate(fields[2])
+			} else {
+				meta.xmp = fields[2]
+			}
+		}
+
+		offset = start + size + 4 // Skip the CRC
+	}
+	return meta
+}
+
+// inflate decompresses zlib data, returning nil on errors
+func inflate(data []byte) []byte {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// readWebPChunk returns the data of the first chunk with the given FourCC in
+// a WebP file. The ICC profile comes before the image data, EXIF and XMP
+// usually after it.
+func readWebPChunk(data []byte, fourCC string) []byte {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil
+	}
+
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		start := offset + 8
+		if start+size > len(data) {
+			return nil
+		}
+		if id == fourCC {
+			return data[start : start+size]
+		}
+		offset = start + size + size%2
+	}
+	return nil
+}
+
+// xmpOrientation matches the orientation property of XMP data, in attribute
+// or element form
+var xmpOrientation = regexp.MustCompile(`(tiff:Orientation\s*=\s*["']|<tiff:Orientation>\s*)[1-8]`)
+
+// resetXMPOrientation returns a copy of XMP data with the orientation set to normal
+func resetXMPOrientation(xmp []byte) []byte {
+	return xmpOrientation.ReplaceAll(xmp, []byte("${1}1"))
+}
@@ -0,0 +1,84 @@
+package fileops
+
+// NaturalLess reports whether a sorts before b in natural order: runs of
+// digits compare by their numeric value, so "page2" sorts before "page10",
+// and letters compare case-insensitively. Paths are compared directory by
+// directory, as "/" sorts before every other character. Names that only
+// differ in case or leading zeros fall back to plain string order.
+func NaturalLess(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// naturalCompare compares a and b in natural order, returning -1, 0 or 1
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Compare the digit runs by value: skip leading zeros, then the
+			// longer run is the larger number, then compare digit by digit
+			starti, startj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			numA, numB := trimZeros(a[starti:i]), trimZeros(b[startj:j])
+			if len(numA) != len(numB) {
+				return compareInts(len(numA), len(numB))
+			}
+			if numA != numB {
+				if numA < numB {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+
+		ca, cb := naturalKey(a[i]), naturalKey(b[j])
+		if ca != cb {
+			return compareInts(int(ca), int(cb))
+		}
+		i++
+		j++
+	}
+	return compareInts(len(a)-i, len(b)-j)
+}
+
+// naturalKey maps a byte to its sort key: path separators first, letters
+// without case
+func naturalKey(c byte) byte {
+	switch {
+	case c == '/' || c == '\\':
+		return 0
+	case c >= 'A' && c <= 'Z':
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// trimZeros removes leading zeros from a run of digits, keeping at least one
+func trimZeros(digits string) string {
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
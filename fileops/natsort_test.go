@@ -0,0 +1,74 @@
+package fileops
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"page2", "page10", true},
+		{"page10", "page2", false},
+		{"page1", "page1", false},
+		{"", "page1", true},
+		{"page", "page1", true},
+		{"page1a", "page1b", true},
+		{"1.5", "1.10", true},
+		{"x99999999999999999999", "x100000000000000000000", true},
+
+		// Letters compare without case
+		{"a", "B", true},
+		{"B", "a", false},
+		{"Page2", "page10", true},
+
+		// Names equal apart from case or leading zeros use plain string order
+		{"A", "a", true},
+		{"a", "A", false},
+		{"page02", "page2", true},
+		{"page2", "page02", false},
+		{"page002", "page2a", true},
+
+		// Paths compare directory by directory
+		{"ch1/p10", "ch1 extra/p1", true},
+		{"ch1 extra/p1", "ch1/p10", false},
+		{`ch1\p10`, `ch1 extra\p1`, true},
+		{"ch1/p10", "ch10/p1", true},
+		{"ch2/p1", "ch10/p1", true},
+	}
+
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNaturalLessSort(t *testing.T) {
+	want := []string{
+		"ch1/p1.jpg",
+		"ch1/p2.jpg",
+		"ch1/p10.jpg",
+		"ch1 extra/p1.jpg",
+		"ch2/P3.jpg",
+		"ch2/p03b.jpg",
+		"ch10/p1.jpg",
+		"cover.jpg",
+	}
+	names := slices.Clone(want)
+	slices.Reverse(names)
+	slices.SortFunc(names, func(a, b string) int {
+		switch {
+		case NaturalLess(a, b):
+			return -1
+		case NaturalLess(b, a):
+			return 1
+		}
+		return 0
+	})
+	if !slices.Equal(names, want) {
+		t.Errorf("sorted %q, want %q", names, want)
+	}
+}
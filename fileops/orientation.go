@@ -0,0 +1,134 @@
+package fileops
+
+import (
+	"encoding/binary"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// EXIF orientation values, see the TIFF/EXIF specification for tag 0x0112
+const (
+	OrientationNormal     = 1
+	OrientationFlipH      = 2
+	OrientationRotate180  = 3
+	OrientationFlipV      = 4
+	OrientationTranspose  = 5
+	OrientationRotate90   = 6
+	OrientationTransverse = 7
+	OrientationRotate270  = 8
+)
+
+const exifOrientationTag = 0x0112
+
+// parseExifOrientation reads the orientation tag from IFD0 of a TIFF structure
+func parseExifOrientation(tiff []byte) int {
+	order, offset := findExifOrientation(tiff)
+	if offset < 0 {
+		return 0
+	}
+	return int(order.Uint16(tiff[offset:]))
+}
+
+// findExifOrientation locates the value of the orientation tag in IFD0 of a
+// TIFF structure. It returns -1 when there is no orientation tag.
+func findExifOrientation(tiff []byte) (binary.ByteOrder, int) {
+	if len(tiff) < 8 {
+		return nil, -1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, -1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return nil, -1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil, -1
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			// SHORT value, stored left-aligned in the value field
+			return order, entry + 8
+		}
+	}
+
+	return nil, -1
+}
+
+// exifOrientation returns the orientation stored in EXIF data, or
+// OrientationNormal when it has no (valid) orientation tag
+func exifOrientation(exif []byte) int {
+	orientation := parseExifOrientation(exif)
+	if orientation < OrientationNormal || orientation > OrientationRotate270 {
+		return OrientationNormal
+	}
+	return orientation
+}
+
+// resetExifOrientation returns a copy of EXIF data with the orientation set
+// to normal, for pages whose pixels have already been turned upright
+func resetExifOrientation(exif []byte) []byte {
+	reset := append([]byte(nil), exif...)
+	if order, offset := findExifOrientation(reset); offset >= 0 {
+		order.PutUint16(reset[offset:], OrientationNormal)
+	}
+	return reset
+}
+
+// ApplyOrientation rotates and flips img so that it displays upright for the
+// given EXIF orientation
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= OrientationNormal || orientation > OrientationRotate270 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstWidth, dstHeight := width, height
+	if orientation >= OrientationTranspose {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case OrientationFlipH:
+				sx, sy = width-1-x, y
+			case OrientationRotate180:
+				sx, sy = width-1-x, height-1-y
+			case OrientationFlipV:
+				sx, sy = x, height-1-y
+			case OrientationTranspose:
+				sx, sy = y, x
+			case OrientationRotate90:
+				sx, sy = y, height-1-x
+			case OrientationTransverse:
+				sx, sy = width-1-y, height-1-x
+			case OrientationRotate270:
+				sx, sy = width-1-y, x
+			}
+
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
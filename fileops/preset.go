@@ -0,0 +1,98 @@
+package fileops
+
+import "strings"
+
+// Preset bundles the image options and archive format suited to a reading device
+type Preset struct {
+	Name        string
+	Description string
+	Format      string // Archive format, e.g. "cbz"
+	Options     Options
+}
+
+// Presets lists the built-in device presets. The first entry is the default.
+var Presets = []Preset{
+	{
+		Name:        "Original",
+		Description: "Full resolution, color, quality 80",
+		Format:      "cbz",
+		Options:     DefaultOptions(),
+	},
+	{
+		Name:        "Kindle",
+		Description: "Kindle Paperwhite, 1236x1648 grayscale, margins cropped, spreads split",
+		Format:      "cbz",
+		Options: Options{
+			Quality:        75,
+			MaxWidth:       1236,
+			MaxHeight:      1648,
+			Grayscale:      GrayscaleForce,
+			AutoCrop:       true,
+			CropTolerance:  DefaultCropTolerance,
+			MaxCropPercent: DefaultMaxCropPercent,
+			SplitSpreads:   true,
+		},
+	},
+	{
+		Name:        "Kobo",
+		Description: "Kobo Libra/Clara, 1264x1680 grayscale, margins cropped, spreads split",
+		Format:      "cbz",
+		Options: Options{
+			Quality:        75,
+			MaxWidth:       1264,
+			MaxHeight:      1680,
+			Grayscale:      GrayscaleForce,
+			AutoCrop:       true,
+			CropTolerance:  DefaultCropTolerance,
+			MaxCropPercent: DefaultMaxCropPercent,
+			SplitSpreads:   true,
+		},
+	},
+	{
+		Name:        "iPad",
+		Description: "iPad, 2048x2732 color",
+		Format:      "cbz",
+		Options: Options{
+			Quality:       85,
+			MaxWidth:      2048,
+			MaxHeight:     2732,
+			Grayscale:     GrayscaleAuto,
+			GrayTolerance: DefaultGrayTolerance,
+		},
+	},
+	{
+		Name:        "Phone",
+		Description: "Phone, 1080x2400 color, margins cropped, spreads split",
+		Format:      "cbz",
+		Options: Options{
+			Quality:        75,
+			MaxWidth:       1080,
+			MaxHeight:      2400,
+			Grayscale:      GrayscaleAuto,
+			GrayTolerance:  DefaultGrayTolerance,
+			AutoCrop:       true,
+			CropTolerance:  DefaultCropTolerance,
+			MaxCropPercent: DefaultMaxCropPercent,
+			SplitSpreads:   true,
+		},
+	},
+}
+
+// FindPreset looks up a preset by name, ignoring case
+func FindPreset(name string) (Preset, bool) {
+	for _, preset := range Presets {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// PresetNames returns the names of all built-in presets
+func PresetNames() []string {
+	names := make([]string, len(Presets))
+	for i, preset := range Presets {
+		names[i] = preset.Name
+	}
+	return names
+}
@@ -0,0 +1,168 @@
+package fileops
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// DefaultQuality is the WebP quality used when no preset overrides it
+const DefaultQuality = 80
+
+// DefaultGrayTolerance is the largest channel difference still treated as gray
+const DefaultGrayTolerance = 12
+
+// DefaultCropTolerance is the largest channel difference from the border color
+// that still counts as margin
+const DefaultCropTolerance = 24
+
+// DefaultMaxCropPercent is the largest share of the width or height that
+// auto-crop may remove
+const DefaultMaxCropPercent = 15
+
+// GrayscaleMode controls when images are converted to grayscale
+type GrayscaleMode int
+
+const (
+	GrayscaleOff   GrayscaleMode = iota // Keep images in color
+	GrayscaleAuto                       // Convert images that are already near-grayscale
+	GrayscaleForce                      // Convert every image, e.g. for e-ink readers
+)
+
+// String returns the name of the grayscale mode
+func (g GrayscaleMode) String() string {
+	switch g {
+	case GrayscaleAuto:
+		return "auto"
+	case GrayscaleForce:
+		return "force"
+	default:
+		return "off"
+	}
+}
+
+// ParseGrayscaleMode parses a grayscale mode name (off, auto or force)
+func ParseGrayscaleMode(name string) (GrayscaleMode, error) {
+	switch strings.ToLower(name) {
+	case "off":
+		return GrayscaleOff, nil
+	case "auto":
+		return GrayscaleAuto, nil
+	case "force":
+		return GrayscaleForce, nil
+	default:
+		return GrayscaleOff, fmt.Errorf("unknown grayscale mode '%s' (use off, auto or force)", name)
+	}
+}
+
+// Options controls how images are transformed before they are encoded
+type Options struct {
+	OutputFormat  string        // Encoder name (WebP, AVIF, JXL), empty for WebP
+	Quality       float32       // Encoder quality (0-100)
+	MaxWidth      int           // Maximum output width in pixels, 0 for no limit
+	MaxHeight     int           // Maximum output height in pixels, 0 for no limit
+	Grayscale     GrayscaleMode // When to convert images to grayscale
+	GrayTolerance int           // Channel difference tolerated by GrayscaleAuto
+
+	AutoCrop       bool    // Trim uniform white or black margins
+	CropTolerance  int     // Channel difference still treated as margin
+	MaxCropPercent float64 // Largest share of the width or height that may be removed
+
+	SplitSpreads bool // Split landscape double-page spreads into two pages
+	RightToLeft  bool // Pages are read right to left (manga)
+
+	ColorProfile ColorProfileMode // Convert embedded ICC profiles to sRGB or embed them
+	Metadata     MetadataMode     // Strip EXIF and XMP data or carry it into WebP pages
+}
+
+// DefaultOptions returns the options used when no preset is selected
+func DefaultOptions() Options {
+	return Options{
+		Quality:        DefaultQuality,
+		Grayscale:      GrayscaleAuto,
+		GrayTolerance:  DefaultGrayTolerance,
+		CropTolerance:  DefaultCropTolerance,
+		MaxCropPercent: DefaultMaxCropPercent,
+	}
+}
+
+// ProcessImage applies the crop, split, resize and color conversions from
+// opts to img. It returns the output pages in reading order: one page, or two
+// when a double-page spread is split.
+func ProcessImage(img image.Image, opts Options) []image.Image {
+	if opts.AutoCrop {
+		img = CropMargins(img, opts.CropTolerance, opts.MaxCropPercent)
+	}
+
+	pages := []image.Image{img}
+	if opts.SplitSpreads {
+		pages = SplitSpread(img, opts.RightToLeft)
+	}
+
+	for i, page := range pages {
+		pages[i] = finishPage(page, opts)
+	}
+
+	return pages
+}
+
+// finishPage applies the resize and color conversions from opts to a page
+func finishPage(page image.Image, opts Options) image.Image {
+	page = resizeToFit(page, opts.MaxWidth, opts.MaxHeight)
+
+	switch opts.Grayscale {
+	case GrayscaleForce:
+		page = toGray(page)
+	case GrayscaleAuto:
+		if isOpaque(page) && IsNearGrayscale(page, opts.GrayTolerance) {
+			page = toGray(page)
+		}
+	}
+
+	return page
+}
+
+// resizeToFit scales img down so it fits within maxWidth x maxHeight, keeping
+// the aspect ratio. Images that already fit are returned unchanged.
+func resizeToFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return img
+	}
+
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		if s := float64(maxHeight) / float64(height); s < scale {
+			scale = s
+		}
+	}
+	if scale >= 1.0 {
+		return img
+	}
+
+	newWidth := max(1, int(float64(width)*scale+0.5))
+	newHeight := max(1, int(float64(height)*scale+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// subImage returns the part of img inside rect, sharing pixels when possible
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,198 @@
+package fileops
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// VP8X feature flags
+const (
+	webpFlagAnimation = 0x02
+	webpFlagXMP       = 0x04
+	webpFlagEXIF      = 0x08
+	webpFlagAlpha     = 0x10
+	webpFlagICC       = 0x20
+)
+
+// riffChunk is a single chunk of a WebP RIFF container
+type riffChunk struct {
+	FourCC string
+	Data   []byte
+}
+
+// parseWebP splits a WebP file into its top-level chunks
+func parseWebP(data []byte) ([]riffChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP file")
+	}
+
+	return parseChunks(data[12:])
+}
+
+// parseChunks splits a sequence of RIFF chunks
+func parseChunks(data []byte) ([]riffChunk, error) {
+	var chunks []riffChunk
+	for offset := 0; offset+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		start := offset + 8
+		if start+size > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk %q", data[offset:offset+4])
+		}
+
+		chunks = append(chunks, riffChunk{
+			FourCC: string(data[offset : offset+4]),
+			Data:   data[start : start+size],
+		})
+		offset = start + size + size%2
+	}
+
+	return chunks, nil
+}
+
+// writeWebP assembles chunks into a WebP RIFF container
+func writeWebP(chunks []riffChunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	writeChunks(&body, chunks)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// writeChunks serializes chunks, padding each to an even length
+func writeChunks(buf *bytes.Buffer, chunks []riffChunk) {
+	for _, chunk := range chunks {
+		buf.WriteString(chunk.FourCC)
+		binary.Write(buf, binary.LittleEndian, uint32(len(chunk.Data)))
+		buf.Write(chunk.Data)
+		if len(chunk.Data)%2 == 1 {
+			buf.WriteByte(0)
+		}
+	}
+}
+
+// bitstreamChunks returns the image data chunks (ALPH, VP8, VP8L) of a WebP
+// file, dropping the container and metadata chunks
+func bitstreamChunks(data []byte) ([]riffChunk, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bitstream []riffChunk
+	for _, chunk := range chunks {
+		switch chunk.FourCC {
+		case "ALPH", "VP8 ", "VP8L":
+			bitstream = append(bitstream, chunk)
+		}
+	}
+	if len(bitstream) == 0 {
+		return nil, fmt.Errorf("WebP file has no image data")
+	}
+	return bitstream, nil
+}
+
+// webpFrames returns the frames of an animated WebP file as still WebP files,
+// or data itself for a still image
+func webpFrames(data []byte) ([][]byte, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, &DecodeError{Format: FormatWebP, Kind: ErrCorruptImage, Err: err}
+	}
+
+	var frames [][]byte
+	for _, chunk := range chunks {
+		if chunk.FourCC != "ANMF" {
+			continue
+		}
+		if len(chunk.Data) < 16 {
+			return nil, &DecodeError{Format: FormatWebP, Kind: ErrTruncatedImage}
+		}
+		width, height := uint24(chunk.Data[6:])+1, uint24(chunk.Data[9:])+1
+
+		bitstream, err := parseChunks(chunk.Data[16:])
+		if err != nil {
+			return nil, &DecodeError{Format: FormatWebP, Kind: ErrCorruptImage, Err: err}
+		}
+		var flags byte
+		for _, c := range bitstream {
+			if c.FourCC == "ALPH" {
+				flags |= webpFlagAlpha
+			}
+		}
+		frames = append(frames, writeWebP(append([]riffChunk{vp8xChunk(flags, width, height)}, bitstream...)))
+	}
+
+	if frames == nil {
+		return [][]byte{data}, nil
+	}
+	return frames, nil
+}
+
+// vp8xChunk builds the extended-format header chunk for a canvas
+func vp8xChunk(flags byte, width, height int) riffChunk {
+	data := make([]byte, 10)
+	data[0] = flags
+	putUint24(data[4:], width-1)
+	putUint24(data[7:], height-1)
+	return riffChunk{FourCC: "VP8X", Data: data}
+}
+
+// putUint24 stores v as a little-endian 24-bit integer
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
+
+// uint24 reads a little-endian 24-bit integer
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// embedMetadata rebuilds a still WebP file of the given size in the
+// extended format, with the ICC profile, EXIF and XMP data from meta stored in
+// their own chunks
+func embedMetadata(data []byte, width, height int, meta imageMetadata) ([]byte, error) {
+	bitstream, err := bitstreamChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	for _, chunk := range bitstream {
+		// Lossy images store alpha in an ALPH chunk, lossless ones flag it in the header
+		if chunk.FourCC == "ALPH" || (chunk.FourCC == "VP8L" && len(chunk.Data) > 4 && chunk.Data[4]&0x10 != 0) {
+			flags |= webpFlagAlpha
+		}
+	}
+
+	if meta.iccProfile != nil {
+		flags |= webpFlagICC
+	}
+	if meta.exif != nil {
+		flags |= webpFlagEXIF
+	}
+	if meta.xmp != nil {
+		flags |= webpFlagXMP
+	}
+
+	// The profile precedes the image data, EXIF and XMP follow it
+	chunks := []riffChunk{vp8xChunk(flags, width, height)}
+	if meta.iccProfile != nil {
+		chunks = append(chunks, riffChunk{FourCC: "ICCP", Data: meta.iccProfile})
+	}
+	chunks = append(chunks, bitstream...)
+	if meta.exif != nil {
+		chunks = append(chunks, riffChunk{FourCC: "EXIF", Data: meta.exif})
+	}
+	if meta.xmp != nil {
+		chunks = append(chunks, riffChunk{FourCC: "XMP ", Data: meta.xmp})
+	}
+
+	return writeWebP(chunks), nil
+}
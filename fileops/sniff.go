@@ -0,0 +1,58 @@
+package fileops
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Image formats recognized by SniffFormat
+const (
+	FormatJPEG = "JPEG"
+	FormatPNG  = "PNG"
+	FormatGIF  = "GIF"
+	FormatBMP  = "BMP"
+	FormatTIFF = "TIFF"
+	FormatWebP = "WEBP"
+)
+
+// sniffLen is the number of header bytes SniffFormat needs
+const sniffLen = 16
+
+// SniffFormat identifies an image format from the magic bytes at the start of
+// a file. It returns an empty string when header is not a supported image.
+func SniffFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("\xFF\xD8\xFF")):
+		return FormatJPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return FormatGIF
+	case bytes.HasPrefix(header, []byte("BM")) && len(header) >= 10 && bytes.Equal(header[6:10], []byte{0, 0, 0, 0}):
+		return FormatBMP
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return FormatTIFF
+	case len(header) >= 15 && string(header[0:4]) == "RIFF" && string(header[8:15]) == "WEBPVP8":
+		return FormatWebP
+	}
+	return ""
+}
+
+// DetectImageFormat reads the start of the file at path and returns its
+// image format, or an empty string if the content is not a supported image
+func DetectImageFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	return SniffFormat(header[:n]), nil
+}
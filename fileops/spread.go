@@ -0,0 +1,43 @@
+package fileops
+
+import (
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// IsSpread reports whether img is a landscape double-page spread
+func IsSpread(img image.Image) bool {
+	bounds := img.Bounds()
+	return bounds.Dx() > bounds.Dy()
+}
+
+// SplitSpread splits a double-page spread into its two pages in reading
+// order. For right-to-left books (manga) the right half comes first.
+// Portrait images are returned unchanged as the only page.
+func SplitSpread(img image.Image, rightToLeft bool) []image.Image {
+	if !IsSpread(img) {
+		return []image.Image{img}
+	}
+
+	bounds := img.Bounds()
+	middle := bounds.Min.X + bounds.Dx()/2
+	left := subImage(img, image.Rect(bounds.Min.X, bounds.Min.Y, middle, bounds.Max.Y))
+	right := subImage(img, image.Rect(middle, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+
+	if rightToLeft {
+		return []image.Image{right, left}
+	}
+	return []image.Image{left, right}
+}
+
+// PageName returns the archive name of page index out of count pages
+// produced from the source file name, without an extension. Split spreads get
+// "a" and "b" suffixes so they sort in reading order next to their neighbors.
+func PageName(name string, index, count int) string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if count <= 1 {
+		return base
+	}
+	return base + string(rune('a'+index))
+}
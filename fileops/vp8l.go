@@ -0,0 +1,595 @@
+//go:build !cgo || purego
+
+package fileops
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// This file implements a pure-Go lossless WebP (VP8L) encoder, used when the
+// binary is built without cgo. It uses the subtract-green and predictor
+// transforms and LZ77 references to the left and upper pixel, which handles
+// the large flat areas of comic pages well. See RFC 9649 for the format.
+
+const (
+	vp8lSignature      = 0x2f
+	vp8lMaxDimension   = 1 << 14
+	vp8lPredictorBits  = 5 // Predictor blocks of 32x32 pixels
+	vp8lMaxCodeLength  = 15
+	vp8lMaxCLCLength   = 7
+	vp8lMinMatch       = 3
+	vp8lMaxMatch       = 4096
+	vp8lNumLengthCodes = 24
+	vp8lNumDistCodes   = 40
+
+	vp8lTransformPredictor     = 0
+	vp8lTransformSubtractGreen = 2
+)
+
+// vp8lCodeLengthOrder is the order in which code length code lengths are stored
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lPredictorModes are the predictors tried for each block: L, T,
+// Average2(L, T), Select, ClampAddSubtractFull and ClampAddSubtractHalf
+var vp8lPredictorModes = []int{1, 2, 7, 11, 12, 13}
+
+// encodeVP8L encodes img as a lossless WebP file
+func encodeVP8L(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return nil, fmt.Errorf("image size %dx%d not supported by lossless WebP", width, height)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	argb := make([]uint32, width*height)
+	alphaUsed := false
+	for i := range argb {
+		p := nrgba.Pix[i*4 : i*4+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		if p[3] != 0xff {
+			alphaUsed = true
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(vp8lSignature, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if alphaUsed {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 3) // Version
+
+	// Subtract green: store red and blue relative to green
+	w.write(1, 1)
+	w.write(vp8lTransformSubtractGreen, 2)
+	for i, p := range argb {
+		green := (p >> 8) & 0xff
+		red := ((p >> 16) - green) & 0xff
+		blue := (p - green) & 0xff
+		argb[i] = p&0xff00ff00 | red<<16 | blue
+	}
+
+	// Predictor: store the difference to a per-block prediction
+	w.write(1, 1)
+	w.write(vp8lTransformPredictor, 2)
+	w.write(vp8lPredictorBits-2, 3)
+	modes, tilesWide := choosePredictors(argb, width, height)
+	modeImage := make([]uint32, len(modes))
+	for i, mode := range modes {
+		modeImage[i] = uint32(mode) << 8
+	}
+	writeEntropyImage(w, modeImage, tilesWide, false)
+	argb = applyPredictors(argb, width, height, modes, tilesWide)
+
+	w.write(0, 1) // No more transforms
+	writeEntropyImage(w, argb, width, true)
+
+	return wrapVP8L(w.bytes()), nil
+}
+
+// wrapVP8L wraps a VP8L bitstream in a WebP RIFF container
+func wrapVP8L(bitstream []byte) []byte {
+	return writeWebP([]riffChunk{{FourCC: "VP8L", Data: bitstream}})
+}
+
+// choosePredictors picks, for every block, the predictor mode with the
+// smallest residuals
+func choosePredictors(argb []uint32, width, height int) ([]int, int) {
+	tileSize := 1 << vp8lPredictorBits
+	tilesWide := (width + tileSize - 1) / tileSize
+	tilesHigh := (height + tileSize - 1) / tileSize
+	modes := make([]int, tilesWide*tilesHigh)
+
+	for ty := 0; ty < tilesHigh; ty++ {
+		for tx := 0; tx < tilesWide; tx++ {
+			bestMode, bestCost := vp8lPredictorModes[0], -1
+			for _, mode := range vp8lPredictorModes {
+				cost := 0
+				for y := max(ty*tileSize, 1); y < min((ty+1)*tileSize, height); y++ {
+					for x := max(tx*tileSize, 1); x < min((tx+1)*tileSize, width); x++ {
+						i := y*width + x
+						residual := subPixels(argb[i], predict(mode, argb[i-1], argb[i-width], argb[i-width-1]))
+						cost += residualCost(residual)
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					bestMode, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesWide+tx] = bestMode
+		}
+	}
+
+	return modes, tilesWide
+}
+
+// applyPredictors replaces every pixel with its prediction residual. The
+// first pixel is predicted as opaque black, the rest of the first row from
+// the left and the first column from the top, as the format requires.
+func applyPredictors(argb []uint32, width, height int, modes []int, tilesWide int) []uint32 {
+	residuals := make([]uint32, len(argb))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var prediction uint32
+			switch {
+			case x == 0 && y == 0:
+				prediction = 0xff000000
+			case y == 0:
+				prediction = argb[i-1]
+			case x == 0:
+				prediction = argb[i-width]
+			default:
+				mode := modes[(y>>vp8lPredictorBits)*tilesWide+(x>>vp8lPredictorBits)]
+				prediction = predict(mode, argb[i-1], argb[i-width], argb[i-width-1])
+			}
+			residuals[i] = subPixels(argb[i], prediction)
+		}
+	}
+	return residuals
+}
+
+// predict computes the prediction of a pixel from its left, top and top-left
+// neighbors for one of the supported predictor modes
+func predict(mode int, left, top, topLeft uint32) uint32 {
+	switch mode {
+	case 1:
+		return left
+	case 2:
+		return top
+	case 7:
+		return average2(left, top)
+	case 11:
+		// Select whichever of left and top is closer to left + top - topLeft
+		distLeft, distTop := 0, 0
+		for shift := 0; shift < 32; shift += 8 {
+			l, t, tl := int(left>>shift&0xff), int(top>>shift&0xff), int(topLeft>>shift&0xff)
+			distLeft += abs(t - tl)
+			distTop += abs(l - tl)
+		}
+		if distLeft < distTop {
+			return left
+		}
+		return top
+	case 12:
+		var out uint32
+		for shift := 0; shift < 32; shift += 8 {
+			l, t, tl := int(left>>shift&0xff), int(top>>shift&0xff), int(topLeft>>shift&0xff)
+			out |= uint32(clamp255(l+t-tl)) << shift
+		}
+		return out
+	case 13:
+		avg := average2(left, top)
+		var out uint32
+		for shift := 0; shift < 32; shift += 8 {
+			a, tl := int(avg>>shift&0xff), int(topLeft>>shift&0xff)
+			out |= uint32(clamp255(a+(a-tl)/2)) << shift
+		}
+		return out
+	}
+	return 0xff000000
+}
+
+// average2 averages a and b per channel, rounding down
+func average2(a, b uint32) uint32 {
+	return ((a^b)&0xfefefefe)>>1 + a&b
+}
+
+// subPixels subtracts b from a per channel, modulo 256
+func subPixels(a, b uint32) uint32 {
+	alphaGreen := (a | 0x00ff00ff) - (b & 0xff00ff00)
+	redBlue := (a | 0xff00ff00) - (b & 0x00ff00ff)
+	return alphaGreen&0xff00ff00 | redBlue&0x00ff00ff
+}
+
+// residualCost estimates how expensive a residual is to store
+func residualCost(residual uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(residual >> shift & 0xff)
+		cost += min(v, 256-v)
+	}
+	return cost
+}
+
+func clamp255(v int) int {
+	return max(0, min(255, v))
+}
+
+// vp8lToken is a literal pixel or a backward reference
+type vp8lToken struct {
+	argb     uint32
+	length   int // 0 for a literal
+	distCode int // Distance code: 1 is the pixel above, 2 the pixel to the left
+}
+
+// writeEntropyImage writes an entropy-coded image: a single prefix code group
+// followed by the literal and backward-reference stream
+func writeEntropyImage(w *bitWriter, argb []uint32, width int, topLevel bool) {
+	tokens := findReferences(argb, width, topLevel)
+
+	green := make([]int, 256+vp8lNumLengthCodes)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	dist := make([]int, vp8lNumDistCodes)
+	for _, t := range tokens {
+		if t.length == 0 {
+			green[t.argb>>8&0xff]++
+			red[t.argb>>16&0xff]++
+			blue[t.argb&0xff]++
+			alpha[t.argb>>24]++
+			continue
+		}
+		lengthCode, _, _ := prefixEncode(t.length)
+		distCode, _, _ := prefixEncode(t.distCode)
+		green[256+lengthCode]++
+		dist[distCode]++
+	}
+
+	w.write(0, 1) // No color cache
+	if topLevel {
+		w.write(0, 1) // No meta prefix codes
+	}
+
+	codes := [5]*huffmanCode{
+		newHuffmanCode(green, vp8lMaxCodeLength),
+		newHuffmanCode(red, vp8lMaxCodeLength),
+		newHuffmanCode(blue, vp8lMaxCodeLength),
+		newHuffmanCode(alpha, vp8lMaxCodeLength),
+		newHuffmanCode(dist, vp8lMaxCodeLength),
+	}
+	for _, code := range codes {
+		code.writeHeader(w)
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].writeSymbol(w, int(t.argb>>8&0xff))
+			codes[1].writeSymbol(w, int(t.argb>>16&0xff))
+			codes[2].writeSymbol(w, int(t.argb&0xff))
+			codes[3].writeSymbol(w, int(t.argb>>24))
+			continue
+		}
+		lengthCode, lengthBits, lengthExtra := prefixEncode(t.length)
+		codes[0].writeSymbol(w, 256+lengthCode)
+		w.write(uint32(lengthExtra), uint(lengthBits))
+		distCode, distBits, distExtra := prefixEncode(t.distCode)
+		codes[4].writeSymbol(w, distCode)
+		w.write(uint32(distExtra), uint(distBits))
+	}
+}
+
+// findReferences turns pixels into tokens, replacing runs that repeat the
+// pixel to the left or the row above with backward references
+func findReferences(argb []uint32, width int, useReferences bool) []vp8lToken {
+	tokens := make([]vp8lToken, 0, len(argb)/4)
+	for i := 0; i < len(argb); {
+		bestLength, bestCode := 0, 0
+		if useReferences {
+			if i >= 1 {
+				bestLength, bestCode = matchLength(argb, i, 1), 2
+			}
+			if i >= width {
+				if length := matchLength(argb, i, width); length > bestLength {
+					bestLength, bestCode = length, 1
+				}
+			}
+		}
+
+		if bestLength >= vp8lMinMatch {
+			tokens = append(tokens, vp8lToken{length: bestLength, distCode: bestCode})
+			i += bestLength
+			continue
+		}
+		tokens = append(tokens, vp8lToken{argb: argb[i]})
+		i++
+	}
+	return tokens
+}
+
+// matchLength counts how many pixels from i repeat the pixels distance back
+func matchLength(argb []uint32, i, distance int) int {
+	length := 0
+	for i+length < len(argb) && length < vp8lMaxMatch && argb[i+length] == argb[i+length-distance] {
+		length++
+	}
+	return length
+}
+
+// prefixEncode splits a length or distance code value (1-based) into its
+// prefix symbol and extra bits
+func prefixEncode(value int) (code, extraBits, extra int) {
+	d := value - 1
+	if d < 2 {
+		return d, 0, 0
+	}
+	highest := 0
+	for v := d; v > 1; v >>= 1 {
+		highest++
+	}
+	second := (d >> (highest - 1)) & 1
+	extraBits = highest - 1
+	return 2*highest + second, extraBits, d & (1<<extraBits - 1)
+}
+
+// huffmanCode is a canonical prefix code. A code with a single used symbol
+// takes no bits per symbol.
+type huffmanCode struct {
+	lengths []int
+	codes   []uint32 // Bit-reversed canonical codes, ready for the LSB-first writer
+	used    []int    // Symbols with a non-zero frequency, in increasing order
+}
+
+// newHuffmanCode builds a length-limited prefix code for the frequencies
+func newHuffmanCode(freqs []int, maxLength int) *huffmanCode {
+	code := &huffmanCode{
+		lengths: make([]int, len(freqs)),
+		codes:   make([]uint32, len(freqs)),
+	}
+	for symbol, f := range freqs {
+		if f > 0 {
+			code.used = append(code.used, symbol)
+		}
+	}
+
+	switch len(code.used) {
+	case 0:
+		code.used = []int{0}
+		code.lengths[0] = 1
+		return code
+	case 1:
+		code.lengths[code.used[0]] = 1
+		return code
+	}
+
+	// Halve the frequencies until the tree fits the length limit
+	scaled := append([]int(nil), freqs...)
+	for {
+		lengths := huffmanLengths(scaled)
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if longest <= maxLength {
+			code.lengths = lengths
+			break
+		}
+		for i, f := range scaled {
+			if f > 0 {
+				scaled[i] = (f + 1) / 2
+			}
+		}
+	}
+
+	// Assign canonical codes: shorter codes first, then by symbol
+	var count [vp8lMaxCodeLength + 2]int
+	for _, l := range code.lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [vp8lMaxCodeLength + 2]uint32
+	c := uint32(0)
+	for l := 1; l <= vp8lMaxCodeLength; l++ {
+		c = (c + uint32(count[l-1])) << 1
+		next[l] = c
+	}
+	for symbol, l := range code.lengths {
+		if l > 0 {
+			code.codes[symbol] = reverseBits(next[l], l)
+			next[l]++
+		}
+	}
+
+	return code
+}
+
+// huffmanLengths computes unrestricted Huffman code lengths
+func huffmanLengths(freqs []int) []int {
+	h := &nodeHeap{}
+	for symbol, f := range freqs {
+		if f > 0 {
+			heap.Push(h, &huffmanNode{weight: f, symbol: symbol})
+		}
+	}
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*huffmanNode)
+		b := heap.Pop(h).(*huffmanNode)
+		heap.Push(h, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b})
+	}
+
+	lengths := make([]int, len(freqs))
+	var walk func(n *huffmanNode, depth int)
+	walk = func(n *huffmanNode, depth int) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(heap.Pop(h).(*huffmanNode), 0)
+	return lengths
+}
+
+// huffmanNode is a node of the tree built by huffmanLengths
+type huffmanNode struct {
+	weight      int
+	symbol      int // -1 for internal nodes
+	left, right *huffmanNode
+}
+
+// nodeHeap is a min-heap of tree nodes ordered by weight
+type nodeHeap []*huffmanNode
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight < h[j].weight
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *nodeHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// writeSymbol writes the code of symbol
+func (c *huffmanCode) writeSymbol(w *bitWriter, symbol int) {
+	if len(c.used) > 1 {
+		w.write(c.codes[symbol], uint(c.lengths[symbol]))
+	}
+}
+
+// writeHeader writes the code, using the compact simple form when possible
+func (c *huffmanCode) writeHeader(w *bitWriter) {
+	if len(c.used) <= 2 && c.used[len(c.used)-1] < 256 {
+		w.write(1, 1) // Simple code
+		w.write(uint32(len(c.used)-1), 1)
+		if c.used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(c.used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(c.used[0]), 8)
+		}
+		if len(c.used) == 2 {
+			w.write(uint32(c.used[1]), 8)
+		}
+		return
+	}
+
+	w.write(0, 1) // Normal code
+
+	// Run-length encode the code lengths: 0-15 literally, 17 and 18 for runs of zeros
+	type clToken struct{ symbol, extra int }
+	var tokens []clToken
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			tokens = append(tokens, clToken{symbol: c.lengths[i]})
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(c.lengths) && c.lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run > 0 {
+			switch {
+			case run >= 11:
+				n := min(run, 138)
+				tokens = append(tokens, clToken{symbol: 18, extra: n - 11})
+				run -= n
+			case run >= 3:
+				tokens = append(tokens, clToken{symbol: 17, extra: run - 3})
+				run = 0
+			default:
+				tokens = append(tokens, clToken{symbol: 0})
+				run--
+			}
+		}
+	}
+
+	freqs := make([]int, 19)
+	for _, t := range tokens {
+		freqs[t.symbol]++
+	}
+	clc := newHuffmanCode(freqs, vp8lMaxCLCLength)
+
+	numCodes := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if clc.lengths[symbol] > 0 && freqs[symbol] > 0 {
+			numCodes = max(numCodes, i+1)
+		}
+	}
+	w.write(uint32(numCodes-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:numCodes] {
+		length := 0
+		if freqs[symbol] > 0 {
+			length = clc.lengths[symbol]
+		}
+		w.write(uint32(length), 3)
+	}
+
+	w.write(0, 1) // Lengths are given for the whole alphabet
+
+	for _, t := range tokens {
+		clc.writeSymbol(w, t.symbol)
+		switch t.symbol {
+		case 17:
+			w.write(uint32(t.extra), 3)
+		case 18:
+			w.write(uint32(t.extra), 7)
+		}
+	}
+}
+
+// reverseBits reverses the lowest n bits of v
+func reverseBits(v uint32, n int) uint32 {
+	var r uint32
+	for i := 0; i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+// bitWriter writes bits least-significant first
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(bits uint32, n uint) {
+	w.acc |= uint64(bits) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
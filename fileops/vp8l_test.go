@@ -0,0 +1,124 @@
+//go:build !cgo || purego
+
+package fileops
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+func TestEncodeVP8LRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name          string
+		width, height int
+		pixel         func(x, y int) color.NRGBA
+	}{
+		{"single pixel", 1, 1, func(x, y int) color.NRGBA {
+			return color.NRGBA{200, 100, 50, 255}
+		}},
+		{"flat", 64, 64, func(x, y int) color.NRGBA {
+			return color.NRGBA{255, 255, 255, 255}
+		}},
+		{"random", 97, 61, func(x, y int) color.NRGBA {
+			v := rng.Uint32()
+			return color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), 255}
+		}},
+		{"gradient", 256, 128, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(y * 2), uint8(x + y), 255}
+		}},
+		{"alpha", 40, 40, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 6), 80, uint8(y * 6), uint8(x * y)}
+		}},
+		{"transparent", 33, 7, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(y), 0, 0}
+		}},
+		{"odd size", 3, 129, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 80), uint8(y), uint8(y * 3), 255}
+		}},
+		{"many tiles", 600, 350, func(x, y int) color.NRGBA {
+			// Lines, flat areas and noise, so the blocks pick different predictors
+			switch {
+			case (x/32+y/32)%3 == 0:
+				v := rng.Uint32()
+				return color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), 255}
+			case y%9 == 0:
+				return color.NRGBA{0, 0, 0, 255}
+			default:
+				return color.NRGBA{uint8(x / 3), uint8(y / 2), 240, 255}
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, tt.width, tt.height))
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					img.SetNRGBA(x, y, tt.pixel(x, y))
+				}
+			}
+
+			data, err := encodeVP8L(img)
+			if err != nil {
+				t.Fatalf("encodeVP8L: %v", err)
+			}
+			decoded, err := xwebp.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("bounds %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want := img.NRGBAAt(x, y); got != want {
+						t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeVP8LSubImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 13)
+	}
+	sub := img.SubImage(image.Rect(10, 20, 45, 31)).(*image.NRGBA)
+
+	data, err := encodeVP8L(sub)
+	if err != nil {
+		t.Fatalf("encodeVP8L: %v", err)
+	}
+	decoded, err := xwebp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got, want := decoded.Bounds().Size(), sub.Bounds().Size(); got != want {
+		t.Fatalf("size %v, want %v", got, want)
+	}
+	for y := 0; y < 11; y++ {
+		for x := 0; x < 35; x++ {
+			got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			if want := sub.NRGBAAt(x+10, y+20); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeVP8LRejectsSize(t *testing.T) {
+	for _, size := range []image.Point{{0, 10}, {10, 0}, {vp8lMaxDimension + 1, 1}} {
+		if _, err := encodeVP8L(image.NewNRGBA(image.Rectangle{Max: size})); err == nil {
+			t.Errorf("encodeVP8L accepted a %dx%d image", size.X, size.Y)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build cgo && !purego
+
+package fileops
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/chai2010/webp"
+)
+
+// WebPLossless reports whether ConvertToWebP always encodes losslessly
+const WebPLossless = false
+
+// ConvertToWebP converts an image to WebP format with specified quality
+func ConvertToWebP(img image.Image, quality float32) ([]byte, error) {
+	var buf bytes.Buffer
+	err := webp.Encode(&buf, img, &webp.Options{Quality: quality})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,14 @@
+//go:build !cgo || purego
+
+package fileops
+
+import "image"
+
+// WebPLossless reports whether ConvertToWebP always encodes losslessly
+const WebPLossless = true
+
+// ConvertToWebP converts an image to lossless WebP format. Builds without cgo
+// have no libwebp, so the quality setting is ignored.
+func ConvertToWebP(img image.Image, quality float32) ([]byte, error) {
+	return encodeVP8L(img)
+}
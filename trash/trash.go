@@ -0,0 +1,323 @@
+package trash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trash is a directory originals are moved to instead of being deleted. It
+// uses the layout of the freedesktop.org trash specification: the moved
+// files and directories are kept in files/, and info/ holds a .trashinfo
+// manifest for each one with its original path, the time it was moved and
+// the archive it went into. The XDG trash of a Linux desktop can therefore
+// be used directly, and items show up in the desktop's trash can.
+type Trash struct {
+	Dir string
+}
+
+// Item is an original kept in the trash
+type Item struct {
+	Name    string    // Name in the files directory
+	Path    string    // Original absolute path
+	Deleted time.Time // When it was moved to the trash
+	Archive string    // Archive the original was converted to
+}
+
+// dateFormat is the DeletionDate format of .trashinfo files, in local time
+const dateFormat = "2006-01-02T15:04:05"
+
+// archiveKey records the archive in .trashinfo files. Items without it were
+// put in the trash by other programs and are never touched.
+const archiveKey = "X-Archive"
+
+// New returns the trash in dir, or the default trash if dir is empty
+func New(dir string) (*Trash, error) {
+	if dir == "" {
+		return Default()
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Trash{Dir: abs}, nil
+}
+
+// Default returns the XDG home trash ($XDG_DATA_HOME/Trash) on Linux and
+// other Unix desktops. On Windows and macOS, whose trash cans use their own
+// formats, originals go to a quarantine directory in the home directory.
+func Default() (*Trash, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the trash directory: %v", err)
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return &Trash{Dir: filepath.Join(home, ".cbz-converter", "quarantine")}, nil
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dataHome) {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return &Trash{Dir: filepath.Join(dataHome, "Trash")}, nil
+}
+
+func (t *Trash) filesDir() string { return filepath.Join(t.Dir, "files") }
+func (t *Trash) infoDir() string  { return filepath.Join(t.Dir, "info") }
+
+func (t *Trash) infoPath(name string) string {
+	return filepath.Join(t.infoDir(), name+".trashinfo")
+}
+
+// Move moves the file or directory at path to the trash, recording the
+// archive it was converted to
+func (t *Trash) Move(path, archivePath string) (*Item, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if archivePath, err = filepath.Abs(archivePath); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{t.filesDir(), t.infoDir()} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create trash directory %s: %v", dir, err)
+		}
+	}
+
+	item := &Item{Path: abs, Deleted: time.Now(), Archive: archivePath}
+	info, err := t.reserve(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest is written first, as the specification requires, so an
+	// interrupted move never leaves an item nobody knows the origin of
+	_, err = info.WriteString(item.manifest())
+	if closeErr := info.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = move(abs, filepath.Join(t.filesDir(), item.Name))
+	}
+	if err != nil {
+		os.Remove(t.infoPath(item.Name))
+		return nil, fmt.Errorf("failed to move %s to the trash: %v", abs, err)
+	}
+	return item, nil
+}
+
+// reserve picks a free name for item, creating its .trashinfo file. Names
+// already in use get a number: "Book (2)".
+func (t *Trash) reserve(item *Item) (*os.File, error) {
+	base := filepath.Base(item.Path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; ; i++ {
+		item.Name = base
+		if i > 1 {
+			item.Name = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+		if _, err := os.Lstat(filepath.Join(t.filesDir(), item.Name)); err == nil {
+			continue
+		}
+		info, err := os.OpenFile(t.infoPath(item.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return info, err
+	}
+}
+
+// manifest returns the .trashinfo contents for the item
+func (i *Item) manifest() string {
+	return fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n%s=%s\n",
+		escapePath(i.Path), i.Deleted.Format(dateFormat), archiveKey, escapePath(i.Archive))
+}
+
+// List returns the items this program moved to the trash, oldest first.
+// Manifests that cannot be read or have no archive are skipped, like the
+// items other programs put in the trash.
+func (t *Trash) List() ([]Item, error) {
+	infos, err := os.ReadDir(t.infoDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	for _, info := range infos {
+		name, ok := strings.CutSuffix(info.Name(), ".trashinfo")
+		if !ok || info.IsDir() {
+			continue
+		}
+		item, err := t.readItem(name)
+		if err == nil && item.Archive != "" {
+			items = append(items, *item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Deleted.Before(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// readItem parses the .trashinfo file of the named item
+func (t *Trash) readItem(name string) (*Item, error) {
+	file, err := os.Open(t.infoPath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	item := &Item{Name: name}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			item.Path, err = url.PathUnescape(value)
+		case "DeletionDate":
+			item.Deleted, err = time.ParseInLocation(dateFormat, value, time.Local)
+		case archiveKey:
+			item.Archive, err = url.PathUnescape(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid trash info for %s: %v", name, err)
+		}
+	}
+	return item, scanner.Err()
+}
+
+// Restore moves an item back to its original path. Nothing is overwritten:
+// the item stays in the trash if something else is there now.
+func (t *Trash) Restore(item Item) error {
+	if _, err := os.Lstat(item.Path); err == nil {
+		return fmt.Errorf("cannot restore %s, the path is taken", item.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.Path), 0755); err != nil {
+		return err
+	}
+	if err := move(filepath.Join(t.filesDir(), item.Name), item.Path); err != nil {
+		return fmt.Errorf("failed to restore %s: %v", item.Path, err)
+	}
+	return os.Remove(t.infoPath(item.Name))
+}
+
+// Purge deletes an item for good
+func (t *Trash) Purge(item Item) error {
+	if err := os.RemoveAll(filepath.Join(t.filesDir(), item.Name)); err != nil {
+		return fmt.Errorf("failed to purge %s: %v", item.Name, err)
+	}
+	return os.Remove(t.infoPath(item.Name))
+}
+
+// ParseAge parses a retention period: a number of days such as "30d" or a
+// duration such as "12h"
+func ParseAge(text string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age '%s' (use e.g. 30d or 12h)", text)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(text)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age '%s' (use e.g. 30d or 12h)", text)
+	}
+	return age, nil
+}
+
+// escapePath URL-escapes a path for a .trashinfo file, keeping the slashes
+func escapePath(path string) string {
+	return (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath()
+}
+
+// move renames src to dst, copying and then deleting when they are on
+// different file systems. dst must not exist.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := os.Lstat(dst); statErr == nil {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file, symbolic link or directory with its contents
+func copyTree(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return os.Chmod(dst, info.Mode().Perm())
+	case info.Mode().IsRegular():
+		return copyFile(src, dst, info.Mode().Perm())
+	default:
+		return fmt.Errorf("cannot copy %s, it is not a regular file", src)
+	}
+}
+
+// copyFile copies the contents of a regular file
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
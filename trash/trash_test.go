@@ -0,0 +1,185 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeBook creates a book directory holding one page
+func writeBook(t *testing.T, dir, name string) string {
+	t.Helper()
+	book := filepath.Join(dir, name)
+	if err := os.MkdirAll(book, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(book, "01.jpg"), []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestMoveRestorePurge(t *testing.T) {
+	src := t.TempDir()
+	trash, err := New(filepath.Join(t.TempDir(), "trash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two books with the same name and characters to escape, and a file
+	first := writeBook(t, src, "Book 50% [EN]")
+	second := writeBook(t, filepath.Join(src, "other"), "Book 50% [EN]")
+	file := filepath.Join(src, "single.cbz")
+	if err := os.WriteFile(file, []byte("zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var moved []*Item
+	for _, path := range []string{first, second, file} {
+		item, err := trash.Move(path, path+".cbz")
+		if err != nil {
+			t.Fatalf("Move(%s): %v", path, err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after the move", path)
+		}
+		moved = append(moved, item)
+	}
+	for i, want := range []string{"Book 50% [EN]", "Book 50% [EN] (2)", "single.cbz"} {
+		if moved[i].Name != want {
+			t.Errorf("item %d is named %q, want %q", i, moved[i].Name, want)
+		}
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("List returned %d items, want 3", len(items))
+	}
+	byName := make(map[string]Item)
+	for _, item := range items {
+		byName[item.Name] = item
+	}
+	for _, want := range moved {
+		got, ok := byName[want.Name]
+		if !ok || got.Path != want.Path || got.Archive != want.Archive || got.Deleted.Unix() != want.Deleted.Unix() {
+			t.Errorf("listed %+v, want %+v", got, *want)
+		}
+	}
+
+	// A restore never overwrites what took the original's place
+	writeBook(t, src, "Book 50% [EN]")
+	if err := trash.Restore(byName["Book 50% [EN]"]); err == nil {
+		t.Errorf("Restore overwrote an existing directory")
+	}
+	if err := os.RemoveAll(first); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Book 50% [EN]", "Book 50% [EN] (2)"} {
+		item := byName[name]
+		if err := trash.Restore(item); err != nil {
+			t.Fatalf("Restore(%s): %v", name, err)
+		}
+		data, err := os.ReadFile(filepath.Join(item.Path, "01.jpg"))
+		if err != nil || string(data) != "Book 50% [EN]" {
+			t.Errorf("restored %s holds %q, %v", item.Path, data, err)
+		}
+	}
+
+	if err := trash.Purge(byName["single.cbz"]); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(trash.Dir, "files", "single.cbz")); !os.IsNotExist(err) {
+		t.Errorf("purged file still in the trash")
+	}
+	if items, err := trash.List(); err != nil || len(items) != 0 {
+		t.Errorf("List after restoring and purging returned %d items, %v", len(items), err)
+	}
+}
+
+func TestListManifests(t *testing.T) {
+	trash := &Trash{Dir: t.TempDir()}
+	manifests := map[string]string{
+		"ours.trashinfo":    "[Trash Info]\nPath=/books/One%20Piece%20v01\nDeletionDate=2024-03-01T10:00:00\nX-Archive=/books/One%20Piece%20v01.cbz\n",
+		"older.trashinfo":   "[Trash Info]\nPath=/books/Akira\nDeletionDate=2023-12-24T08:30:00\nX-Archive=/books/Akira.cbz\n",
+		"foreign.trashinfo": "[Trash Info]\nPath=/home/user/notes.txt\nDeletionDate=2024-01-01T00:00:00\n",
+		"broken.trashinfo":  "[Trash Info]\nPath=/books/Broken\nDeletionDate=yesterday\nX-Archive=/books/Broken.cbz\n",
+		"badpath.trashinfo": "[Trash Info]\nPath=/books/%zz\nDeletionDate=2024-01-01T00:00:00\nX-Archive=/books/x.cbz\n",
+		"unrelated.txt":     "Path=/books/x\nX-Archive=/books/x.cbz\n",
+	}
+	if err := os.MkdirAll(trash.infoDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range manifests {
+		if err := os.WriteFile(filepath.Join(trash.infoDir(), name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []Item{
+		{Name: "older", Path: "/books/Akira", Archive: "/books/Akira.cbz", Deleted: time.Date(2023, 12, 24, 8, 30, 0, 0, time.Local)},
+		{Name: "ours", Path: "/books/One Piece v01", Archive: "/books/One Piece v01.cbz", Deleted: time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("List returned %+v, want %+v", items, want)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d is %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestListMissingTrash(t *testing.T) {
+	trash := &Trash{Dir: filepath.Join(t.TempDir(), "none")}
+	if items, err := trash.List(); err != nil || items != nil {
+		t.Errorf("List of a missing trash returned %v, %v", items, err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	item := Item{
+		Path:    "/books/Saga #12 (50%)",
+		Archive: "/out/Saga #12 (50%).cbz",
+		Deleted: time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local),
+	}
+	want := "[Trash Info]\nPath=/books/Saga%20%2312%20%2850%25%29\nDeletionDate=2024-05-06T07:08:09\nX-Archive=/out/Saga%20%2312%20%2850%25%29.cbz\n"
+	if got := item.manifest(); got != want {
+		t.Errorf("manifest is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+		ok   bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"0d", 0, true},
+		{"12h", 12 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"-1d", 0, false},
+		{"-5h", 0, false},
+		{"d", 0, false},
+		{"1.5d", 0, false},
+		{"30", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAge(tt.text)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v (ok %v)", tt.text, got, err, tt.want, tt.ok)
+		}
+	}
+}
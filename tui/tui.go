@@ -1,789 +1,1479 @@
-package tui
-
-import (
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
-
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
-)
-
-// AppState represents the current state of the application
-type AppState int
-
-const (
-	StateSelectDirectory AppState = iota
-	StateSelectMode               // New state for mode detection
-	StateSelectItems              // New state for selecting items (files or directories)
-	StateSelectFormat
-	StateProcessing
-	StateComplete
-	StateError
-)
-
-// OperationMode represents the mode of operation (files or directories)
-type OperationMode int
-
-const (
-	ModeUnknown     OperationMode = iota
-	ModeDirectories               // Directory selection mode
-	ModeFiles                     // File selection mode
-)
-
-// Model represents the application state
-type Model struct {
-	state          AppState
-	directoryPath  string
-	selectedFormat string
-	deleteOriginal bool
-	formats        []string
-	cursor         int
-	width          int
-	height         int
-	processingMsg  string
-	errorMsg       string
-	completedDirs  []string
-	totalDirs      int
-	currentDir     int
-	currentDirName string
-	processedFiles int
-	totalFiles     int
-	conversionLog  []string
-
-	// New fields for mode-based operation
-	operationMode  OperationMode
-	availableItems []string        // List of available files or directories
-	selectedItems  map[string]bool // Map of selected items
-	itemStartIndex int             // For scrolling through items
-	itemsPerPage   int             // Number of items to display per page
-
-	// Preview system
-	previewContent string // Current preview content
-	previewType    string // Type of preview (image, text, video, etc.)
-	showPreview    bool   // Whether to show preview panel
-}
-
-// InitialModel returns the initial state of the application
-func InitialModel() Model {
-	return Model{
-		state:          StateSelectDirectory,
-		formats:        []string{"CBZ (ZIP)", "CBR (RAR)", "CB7Z (7Z)"},
-		selectedFormat: "CBZ (ZIP)",
-		deleteOriginal: false,
-		cursor:         0,
-		operationMode:  ModeUnknown,
-		selectedItems:  make(map[string]bool),
-		itemsPerPage:   10,
-	}
-}
-
-// Init implements the tea.Model interface
-func (m Model) Init() tea.Cmd {
-	return nil
-}
-
-// determineOperationMode scans the directory to determine if it contains only files or only directories
-func (m *Model) determineOperationMode() error {
-	hasFiles := false
-	hasDirs := false
-	m.availableItems = []string{} // Reset available items
-
-	entries, err := os.ReadDir(m.directoryPath)
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range entries {
-		// Skip hidden files and directories
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
-		}
-
-		if entry.IsDir() {
-			hasDirs = true
-			m.availableItems = append(m.availableItems, entry.Name())
-		} else {
-			hasFiles = true
-			m.availableItems = append(m.availableItems, entry.Name())
-		}
-
-		// If we find both files and directories, we can stop
-		if hasFiles && hasDirs {
-			return fmt.Errorf("directory contains both files and directories - please choose a directory with only files or only directories")
-		}
-	}
-
-	if hasDirs {
-		m.operationMode = ModeDirectories
-	} else if hasFiles {
-		m.operationMode = ModeFiles
-	} else {
-		return fmt.Errorf("directory is empty")
-	}
-
-	return nil
-}
-
-// Update implements the tea.Model interface
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.itemsPerPage = (m.height - 10) // Adjust items per page based on window height
-		if m.itemsPerPage < 5 {
-			m.itemsPerPage = 5 // Minimum items to show
-		}
-		return m, nil
-
-	case DirectoryCountMsg:
-		m.totalDirs = msg.TotalDirs
-		m.state = StateProcessing
-		// Start processing the directories
-		return m, m.processDirectories(msg.Directories)
-
-	case ProcessDirectoryMsg:
-		// Process the current directory
-		if msg.CurrentIndex >= len(msg.Directories) {
-			// All directories processed
-			return m, tea.Cmd(func() tea.Msg {
-				return ProcessingCompleteMsg{
-					CompletedDirs: msg.CompletedDirs,
-					TotalDirs:     len(msg.Directories),
-				}
-			})
-		}
-
-		// Update progress
-		m.currentDir = msg.CurrentIndex + 1
-		m.currentDirName = filepath.Base(msg.Directories[msg.CurrentIndex])
-		m.processingMsg = fmt.Sprintf("Processing %s...", filepath.Base(msg.Directories[msg.CurrentIndex]))
-
-		// Process this item
-		itemPath := msg.Directories[msg.CurrentIndex]
-		format := strings.ToLower(strings.Split(m.selectedFormat, " ")[0])
-
-		var err error
-		if m.operationMode == ModeDirectories {
-			// Process directory
-			parentDir := filepath.Dir(itemPath)
-			dirName := filepath.Base(itemPath)
-			archivePath := filepath.Join(parentDir, dirName+"."+format)
-
-			// Create the archive (silent version)
-			err = CreateSilentZipArchive(itemPath, archivePath)
-		} else {
-			// Process files
-			// Create a temporary directory to hold the files
-			tempDir, tempErr := os.MkdirTemp("", "cbz-temp-*")
-			if tempErr != nil {
-				m.state = StateError
-				m.errorMsg = fmt.Sprintf("Failed to create temp directory: %v", tempErr)
-				return m, nil
-			}
-			defer os.RemoveAll(tempDir)
-
-			// Copy selected files to temp directory
-			destPath := filepath.Join(tempDir, filepath.Base(itemPath))
-			if err := copyFile(itemPath, destPath); err != nil {
-				m.state = StateError
-				m.errorMsg = fmt.Sprintf("Failed to copy file: %v", err)
-				return m, nil
-			}
-
-			// Create archive from temp directory
-			// Use the directory name as the archive name
-			archiveName := filepath.Base(m.directoryPath)
-			archivePath := filepath.Join(m.directoryPath, archiveName+"."+format)
-			err = CreateSilentZipArchive(tempDir, archivePath)
-		}
-
-		completedDirs := msg.CompletedDirs
-		if err == nil {
-			completedDirs = append(completedDirs, itemPath)
-			// Delete the original if flag is set
-			if m.deleteOriginal {
-				os.RemoveAll(itemPath)
-			}
-		}
-
-		// Process next directory with a small delay to show progress
-		return m, tea.Cmd(func() tea.Msg {
-			time.Sleep(500 * time.Millisecond) // Small delay to show progress
-			return ProcessDirectoryMsg{
-				Directories:   msg.Directories,
-				CurrentIndex:  msg.CurrentIndex + 1,
-				CompletedDirs: completedDirs,
-			}
-		})
-
-	case ProcessingCompleteMsg:
-		m.state = StateComplete
-		m.completedDirs = msg.CompletedDirs
-		m.totalDirs = msg.TotalDirs
-		return m, nil
-
-	case ProgressMsg:
-		m.currentDirName = msg.CurrentDir
-		m.currentDir = msg.CurrentDirNum
-		m.totalDirs = msg.TotalDirs
-		m.processedFiles = msg.ProcessedFiles
-		m.totalFiles = msg.TotalFiles
-		m.processingMsg = msg.Message
-		return m, nil
-
-	case FileProcessedMsg:
-		logEntry := fmt.Sprintf("✓ %s (%s) → %s", msg.FileName, msg.FileType, msg.ConvertedTo)
-		m.conversionLog = append(m.conversionLog, logEntry)
-		// Keep only last 10 log entries to avoid cluttering
-		if len(m.conversionLog) > 10 {
-			m.conversionLog = m.conversionLog[len(m.conversionLog)-10:]
-		}
-		return m, nil
-
-	case tea.KeyMsg:
-		switch m.state {
-		case StateSelectDirectory:
-			return m.updateDirectorySelection(msg)
-		case StateSelectItems:
-			return m.updateItemSelection(msg)
-		case StateSelectFormat:
-			return m.updateFormatSelection(msg)
-		case StateProcessing:
-			return m.updateProcessing(msg)
-		case StateComplete, StateError:
-			return m.updateComplete(msg)
-		}
-	}
-
-	return m, nil
-}
-
-// View implements the tea.Model interface
-func (m Model) View() string {
-	switch m.state {
-	case StateSelectDirectory:
-		return m.viewDirectorySelection()
-	case StateSelectItems:
-		return m.viewItemSelection()
-	case StateSelectFormat:
-		return m.viewFormatSelection()
-	case StateProcessing:
-		return m.viewProcessing()
-	case StateComplete:
-		return m.viewComplete()
-	case StateError:
-		return m.viewError()
-	default:
-		return "Unknown state"
-	}
-}
-
-// updateDirectorySelection handles input during directory selection
-func (m Model) updateDirectorySelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q":
-		return m, tea.Quit
-	case "enter":
-		if m.directoryPath != "" {
-			// Validate directory exists
-			if _, err := os.Stat(m.directoryPath); os.IsNotExist(err) {
-				m.state = StateError
-				m.errorMsg = fmt.Sprintf("Directory does not exist: %s", m.directoryPath)
-				return m, nil
-			}
-
-			// Determine operation mode
-			if err := m.determineOperationMode(); err != nil {
-				m.state = StateError
-				m.errorMsg = err.Error()
-				return m, nil
-			}
-
-			m.state = StateSelectItems
-			m.cursor = 0 // Reset cursor for item selection
-		}
-	case "backspace":
-		if len(m.directoryPath) > 0 {
-			m.directoryPath = m.directoryPath[:len(m.directoryPath)-1]
-		}
-	default:
-		if len(msg.String()) == 1 {
-			m.directoryPath += msg.String()
-		}
-	}
-	return m, nil
-}
-
-// updateFormatSelection handles input during format selection
-func (m Model) updateFormatSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q":
-		return m, tea.Quit
-	case "up", "k":
-		if m.cursor > 0 {
-			m.cursor--
-		}
-	case "down", "j":
-		if m.cursor < len(m.formats)-1 {
-			m.cursor++
-		}
-	case "enter":
-		m.selectedFormat = m.formats[m.cursor]
-		m.state = StateProcessing
-		return m, m.startProcessing()
-	case "tab":
-		m.deleteOriginal = !m.deleteOriginal
-	}
-	return m, nil
-}
-
-// updateProcessing handles input during processing
-func (m Model) updateProcessing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q":
-		return m, tea.Quit
-	}
-	return m, nil
-}
-
-// updateComplete handles input when processing is complete
-func (m Model) updateComplete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q", "enter":
-		return m, tea.Quit
-	case "r":
-		// Restart the application
-		return InitialModel(), nil
-	}
-	return m, nil
-}
-
-// startProcessing begins the directory processing
-func (m Model) startProcessing() tea.Cmd {
-	// First, count directories and set up initial progress
-	return m.countDirectories()
-}
-
-// countDirectories counts the total directories or files to process
-func (m Model) countDirectories() tea.Cmd {
-	return func() tea.Msg {
-		var items []string
-
-		// Add selected items to process
-		for item := range m.selectedItems {
-			fullPath := filepath.Join(m.directoryPath, item)
-			items = append(items, fullPath)
-		}
-
-		return DirectoryCountMsg{
-			TotalDirs:   len(items),
-			Directories: items,
-		}
-	}
-}
-
-// DirectoryCountMsg is sent when directory counting is complete
-type DirectoryCountMsg struct {
-	TotalDirs   int
-	Directories []string
-}
-
-// processDirectories processes directories with progress updates
-func (m Model) processDirectories(directories []string) tea.Cmd {
-	return func() tea.Msg {
-		// Start processing the first directory
-		return ProcessDirectoryMsg{
-			Directories:   directories,
-			CurrentIndex:  0,
-			CompletedDirs: []string{},
-		}
-	}
-}
-
-// ProcessDirectoryMsg is sent to process the next directory
-type ProcessDirectoryMsg struct {
-	Directories   []string
-	CurrentIndex  int
-	CompletedDirs []string
-}
-
-// ProcessingCompleteMsg is sent when processing is complete
-type ProcessingCompleteMsg struct {
-	CompletedDirs []string
-	TotalDirs     int
-}
-
-// ProgressMsg is sent during processing to update progress
-type ProgressMsg struct {
-	CurrentDir     string
-	CurrentDirNum  int
-	TotalDirs      int
-	ProcessedFiles int
-	TotalFiles     int
-	Message        string
-}
-
-// FileProcessedMsg is sent when a file is processed
-type FileProcessedMsg struct {
-	FileName    string
-	FileType    string
-	ConvertedTo string
-}
-
-// viewDirectorySelection renders the directory selection screen
-func (m Model) viewDirectorySelection() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("205")).
-		Render("📁 CBZ WebP Converter")
-
-	instruction := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Enter the directory path to process:")
-
-	input := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("238")).
-		Padding(0, 1).
-		Render(m.directoryPath + "█")
-
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Press Enter to continue, Ctrl+C or 'q' to quit")
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-		lipgloss.JoinVertical(lipgloss.Center,
-			title,
-			"",
-			instruction,
-			"",
-			input,
-			"",
-			help,
-		),
-	)
-}
-
-// viewFormatSelection renders the format selection screen
-func (m Model) viewFormatSelection() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("205")).
-		Render("📁 CBZ WebP Converter")
-
-	directory := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(fmt.Sprintf("Directory: %s", m.directoryPath))
-
-	instruction := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Select archive format:")
-
-	var formatOptions []string
-	for i, format := range m.formats {
-		cursor := " "
-		if m.cursor == i {
-			cursor = ">"
-		}
-		formatOptions = append(formatOptions, fmt.Sprintf("%s %s", cursor, format))
-	}
-
-	formats := lipgloss.JoinVertical(lipgloss.Left, formatOptions...)
-
-	deleteOption := " "
-	if m.deleteOriginal {
-		deleteOption = "✓"
-	}
-	deleteText := lipgloss.NewStyle().
-		Render(fmt.Sprintf("%s Delete original files after conversion", deleteOption))
-
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Use ↑/↓ to navigate, Tab to toggle delete option, Enter to start, Ctrl+C or 'q' to quit")
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-		lipgloss.JoinVertical(lipgloss.Center,
-			title,
-			"",
-			directory,
-			"",
-			instruction,
-			"",
-			formats,
-			"",
-			deleteText,
-			"",
-			help,
-		),
-	)
-}
-
-// viewProcessing renders the processing screen
-func (m Model) viewProcessing() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("205")).
-		Render("🔄 Processing...")
-
-	// Overall progress
-	overallProgress := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(fmt.Sprintf("Directories: %d/%d", m.currentDir, m.totalDirs))
-
-	// Current directory info
-	currentDirInfo := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("220")).
-		Render(fmt.Sprintf("Current: %s", m.currentDirName))
-
-	// Progress bar
-	progressBar := m.renderProgressBar()
-
-	// Status message
-	statusMsg := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(m.processingMsg)
-
-	// Help text
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Press Ctrl+C or 'q' to quit")
-
-	content := lipgloss.JoinVertical(lipgloss.Center,
-		title,
-		"",
-		overallProgress,
-		currentDirInfo,
-		"",
-		progressBar,
-		"",
-		statusMsg,
-		"",
-		help,
-	)
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
-}
-
-// renderProgressBar creates a visual progress bar
-func (m Model) renderProgressBar() string {
-	if m.totalDirs == 0 {
-		return ""
-	}
-
-	progress := float64(m.currentDir) / float64(m.totalDirs)
-	barWidth := 30
-	filledWidth := int(progress * float64(barWidth))
-
-	bar := "["
-	for i := 0; i < barWidth; i++ {
-		if i < filledWidth {
-			bar += "█"
-		} else {
-			bar += "░"
-		}
-	}
-	bar += "]"
-
-	percentage := int(progress * 100)
-
-	return lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
-		Render(fmt.Sprintf("%s %d%%", bar, percentage))
-}
-
-// viewComplete renders the completion screen
-func (m Model) viewComplete() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("46")).
-		Render("✅ Processing Complete!")
-
-	summary := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(fmt.Sprintf("Successfully processed %d directories", len(m.completedDirs)))
-
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Press Enter or 'q' to quit, 'r' to restart")
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-		lipgloss.JoinVertical(lipgloss.Center,
-			title,
-			"",
-			summary,
-			"",
-			help,
-		),
-	)
-}
-
-// updateItemSelection handles input during item selection
-func (m Model) updateItemSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+c", "q":
-		return m, tea.Quit
-	case "up", "k":
-		if m.cursor > 0 {
-			m.cursor--
-			// Adjust start index if cursor moves above visible area
-			if m.cursor < m.itemStartIndex {
-				m.itemStartIndex = m.cursor
-			}
-		}
-	case "down", "j":
-		if m.cursor < len(m.availableItems)-1 {
-			m.cursor++
-			// Adjust start index if cursor moves below visible area
-			if m.cursor >= m.itemStartIndex+m.itemsPerPage {
-				m.itemStartIndex = m.cursor - m.itemsPerPage + 1
-			}
-		}
-	case "space", " ":
-		// Toggle selection of current item
-		if m.cursor >= 0 && m.cursor < len(m.availableItems) {
-			currentItem := m.availableItems[m.cursor]
-			if m.selectedItems[currentItem] {
-				delete(m.selectedItems, currentItem)
-			} else {
-				m.selectedItems[currentItem] = true
-			}
-		}
-	case "enter":
-		// Only proceed if at least one item is selected
-		if len(m.selectedItems) > 0 {
-			m.state = StateSelectFormat
-		}
-	case "a":
-		// Select all items
-		for _, item := range m.availableItems {
-			m.selectedItems[item] = true
-		}
-	case "n":
-		// Deselect all items
-		m.selectedItems = make(map[string]bool)
-	}
-	return m, nil
-}
-
-// viewItemSelection renders the item selection screen
-func (m Model) viewItemSelection() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("205")).
-		Render("📁 CBZ WebP Converter")
-
-	modeText := "Select directories to archive"
-	if m.operationMode == ModeFiles {
-		modeText = "Select files to include in archive"
-	}
-
-	instruction := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(modeText)
-
-	// Calculate visible items
-	endIndex := m.itemStartIndex + m.itemsPerPage
-	if endIndex > len(m.availableItems) {
-		endIndex = len(m.availableItems)
-	}
-	visibleItems := m.availableItems[m.itemStartIndex:endIndex]
-
-	// Build the list of items
-	var itemList []string
-	for i, item := range visibleItems {
-		cursor := " "
-		if m.itemStartIndex+i == m.cursor {
-			cursor = ">"
-		}
-
-		checkbox := "[ ]"
-		if m.selectedItems[item] {
-			checkbox = "[✓]"
-		}
-
-		itemText := fmt.Sprintf("%s %s %s", cursor, checkbox, item)
-		if m.itemStartIndex+i == m.cursor {
-			itemText = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("205")).
-				Render(itemText)
-		}
-		itemList = append(itemList, itemText)
-	}
-
-	items := lipgloss.JoinVertical(lipgloss.Left, itemList...)
-
-	// Show scrollbar if needed
-	if len(m.availableItems) > m.itemsPerPage {
-		scrollPosition := fmt.Sprintf("(%d/%d)", m.cursor+1, len(m.availableItems))
-		items = lipgloss.JoinHorizontal(lipgloss.Top, items, "  ", scrollPosition)
-	}
-
-	selectedCount := fmt.Sprintf("Selected: %d/%d", len(m.selectedItems), len(m.availableItems))
-
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("↑/↓: Navigate • Space: Toggle • a: Select All • n: None • Enter: Continue • Ctrl+c/q: Quit")
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-		lipgloss.JoinVertical(lipgloss.Center,
-			title,
-			"",
-			instruction,
-			"",
-			items,
-			"",
-			selectedCount,
-			"",
-			help,
-		),
-	)
-}
-
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	return err
-}
-
-// viewError renders the error screen
-func (m Model) viewError() string {
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("196")).
-		Render("❌ Error")
-
-	error := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render(m.errorMsg)
-
-	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Render("Press Enter or 'q' to quit, 'r' to restart")
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-		lipgloss.JoinVertical(lipgloss.Center,
-			title,
-			"",
-			error,
-			"",
-			help,
-		),
-	)
-}
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"scottgcooper-cbz-webp-converter/archive"
+	"scottgcooper-cbz-webp-converter/fileops"
+	"scottgcooper-cbz-webp-converter/trash"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AppState represents the current state of the application
+type AppState int
+
+const (
+	StateSelectDirectory AppState = iota
+	StateSelectMode               // New state for mode detection
+	StateSelectItems              // New state for selecting items (files or directories)
+	StateSelectFormat
+	StateConfirm // Shows what would be archived before processing starts
+	StateProcessing
+	StateComplete
+	StateError
+)
+
+// OperationMode represents the mode of operation (files or directories)
+type OperationMode int
+
+const (
+	ModeUnknown     OperationMode = iota
+	ModeDirectories               // Directory selection mode
+	ModeFiles                     // File selection mode
+	ModeRecursive                 // Book directories anywhere below, shown as a tree
+	ModeMixed                     // Directories with loose files next to them
+)
+
+// Model represents the application state
+type Model struct {
+	state          AppState
+	directoryPath  string
+	selectedFormat string
+	deleteOriginal bool
+	trash          *trash.Trash // Where originals are moved instead of being deleted
+	trashDir       string       // Directory originals are moved to, empty for the default trash
+	editingTrash   bool         // Typing goes to the trash directory
+	formats        []string
+	presetIndex    int             // Index into fileops.Presets
+	imageOptions   fileops.Options // Image options, starting from the selected preset
+	renumberPages  bool            // Name pages 0001.webp, 0002.webp... in reading order
+	imagesOnly     bool            // Store images only, leaving out other files
+	includeHidden  bool            // List and store files starting with a dot
+	symlinks       archive.SymlinkMode
+	comicInfo      archive.ComicInfoMode
+	nameTemplate   int                   // Index into archive.NameTemplates
+	collision      archive.CollisionMode // What to do when an archive name is taken
+	namer          *archive.Namer        // Picks archive names while processing
+	outputRoot     string                // Directory archives are written below, empty for next to the sources
+	editingOutput  bool                  // Typing goes to the output directory
+	plans          []itemPlan            // What processing would do, shown on the confirmation screen
+	planGeneration int                   // Counts plan requests, so plans for earlier settings are dropped
+	cursor         int
+	width          int
+	height         int
+	processingMsg  string
+	errorMsg       string
+	completedDirs  []string
+	failures       []string // Items that were not archived or not deleted, with the reason
+	totalDirs      int
+	currentDir     int
+	currentDirName string
+	processedFiles int
+	totalFiles     int
+	conversionLog  []string
+
+	// New fields for mode-based operation
+	operationMode  OperationMode
+	availableItems []string                         // List of available files or directories
+	bookDirs       map[string]bool                  // Directories holding a book in recursive mode, the others are branches
+	fileItems      map[string]bool                  // Items that are files rather than directories
+	looseModes     map[string]archive.LooseFileMode // How each selected loose file is stored in mixed mode
+	selectedItems  map[string]bool                  // Map of selected items
+	itemStartIndex int                              // For scrolling through items
+	itemsPerPage   int                              // Number of items to display per page
+
+	// Preview system
+	previewContent string // Current preview content
+	previewType    string // Type of preview (image, text, video, etc.)
+	showPreview    bool   // Whether to show preview panel
+}
+
+// InitialModel returns the initial state of the application
+func InitialModel() Model {
+	return Model{
+		state:          StateSelectDirectory,
+		formats:        []string{"CBZ (ZIP)", "CBR (RAR)", "CB7Z (7Z)"},
+		selectedFormat: "CBZ (ZIP)",
+		deleteOriginal: false,
+		imageOptions:   fileops.Presets[0].Options,
+		cursor:         0,
+		operationMode:  ModeUnknown,
+		selectedItems:  make(map[string]bool),
+		itemsPerPage:   10,
+	}
+}
+
+// Init implements the tea.Model interface
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+// determineOperationMode scans the directory to determine if it contains files, directories or both
+func (m *Model) determineOperationMode() error {
+	hasFiles := false
+	hasDirs := false
+	m.availableItems = []string{} // Reset available items
+	m.fileItems = make(map[string]bool)
+	m.looseModes = make(map[string]archive.LooseFileMode)
+
+	// Hidden files, symlinks and special files follow the same policy as
+	// the archive contents
+	entries, err := archive.ReadDir(m.directoryPath, m.archiveOptions().Filter)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			hasDirs = true
+			m.availableItems = append(m.availableItems, entry.Name())
+		} else if m.archiveOptions().Filter.Allows(archive.Entry{Name: entry.Name(), IsImage: true}) {
+			// Junk files like Thumbs.db are left out of archives, so they
+			// are not offered either
+			hasFiles = true
+			m.availableItems = append(m.availableItems, entry.Name())
+			m.fileItems[entry.Name()] = true
+		}
+	}
+
+	sort.SliceStable(m.availableItems, func(i, j int) bool {
+		return fileops.NaturalLess(m.availableItems[i], m.availableItems[j])
+	})
+
+	if hasDirs && hasFiles {
+		m.operationMode = ModeMixed
+	} else if hasDirs {
+		m.operationMode = ModeDirectories
+	} else if hasFiles {
+		m.operationMode = ModeFiles
+	} else {
+		return fmt.Errorf("directory is empty")
+	}
+
+	return nil
+}
+
+// Update implements the tea.Model interface
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.itemsPerPage = (m.height - 10) // Adjust items per page based on window height
+		if m.itemsPerPage < 5 {
+			m.itemsPerPage = 5 // Minimum items to show
+		}
+		return m, nil
+
+	case DirectoryCountMsg:
+		m.totalDirs = msg.TotalDirs
+		m.state = StateProcessing
+		// Start processing the directories
+		return m, m.processDirectories(msg.Directories)
+
+	case ProcessDirectoryMsg:
+		// Process the current directory
+		if msg.CurrentIndex >= len(msg.Directories) {
+			// All directories processed
+			return m, tea.Cmd(func() tea.Msg {
+				return ProcessingCompleteMsg{
+					CompletedDirs: msg.CompletedDirs,
+					Failures:      msg.Failures,
+					TotalDirs:     len(msg.Directories),
+				}
+			})
+		}
+
+		// Update progress
+		m.currentDir = msg.CurrentIndex + 1
+		m.currentDirName = filepath.Base(msg.Directories[msg.CurrentIndex])
+		m.processingMsg = fmt.Sprintf("Processing %s...", filepath.Base(msg.Directories[msg.CurrentIndex]))
+
+		// Process this item
+		itemPath := msg.Directories[msg.CurrentIndex]
+		format := strings.ToLower(strings.Split(m.selectedFormat, " ")[0])
+
+		source, parentDir, opts := m.itemSource(itemPath)
+		var archivePath string
+		outDir, err := archive.OutputDir(m.directoryPath, m.outputRoot, parentDir)
+		if err == nil {
+			archivePath, err = m.namer.Path(outDir, filepath.Base(itemPath), format)
+		}
+
+		// Create the archive (silent version)
+		var report *archive.Report
+		if err == nil {
+			report, err = CreateSilentArchive(source, archivePath, format, opts)
+		}
+
+		// Renumbered pages lose their names, so record next to the archive
+		// which source file each one was
+		if err == nil && m.renumberPages {
+			err = writePageMapping(report)
+		}
+
+		// Move the original to the trash if flag is set, once the archive
+		// has been read back. Files attached to every directory are left in
+		// place.
+		if err == nil && m.deleteOriginal {
+			if verifyErr := archive.VerifyArchive(source, report, opts); verifyErr != nil {
+				err = fmt.Errorf("verification failed, the originals were kept: %v", verifyErr)
+			}
+		}
+		if err == nil && m.deleteOriginal && source == "" {
+			for _, file := range opts.Attach {
+				if _, err = m.trash.Move(file, archivePath); err != nil {
+					break
+				}
+			}
+		} else if err == nil && m.deleteOriginal {
+			_, err = m.trash.Move(itemPath, archivePath)
+		}
+
+		completedDirs := msg.CompletedDirs
+		failures := msg.Failures
+		if err == nil {
+			completedDirs = append(completedDirs, itemPath)
+		} else {
+			failures = append(failures, fmt.Sprintf("%s: %v", filepath.Base(itemPath), err))
+		}
+
+		// Process next directory with a small delay to show progress
+		return m, tea.Cmd(func() tea.Msg {
+			time.Sleep(500 * time.Millisecond) // Small delay to show progress
+			return ProcessDirectoryMsg{
+				Directories:   msg.Directories,
+				CurrentIndex:  msg.CurrentIndex + 1,
+				CompletedDirs: completedDirs,
+				Failures:      failures,
+			}
+		})
+
+	case PlanMsg:
+		// A slow plan for settings that were changed since must not replace
+		// the current one
+		if msg.Generation == m.planGeneration {
+			m.plans = msg.Plans
+		}
+		return m, nil
+
+	case ProcessingCompleteMsg:
+		m.state = StateComplete
+		m.completedDirs = msg.CompletedDirs
+		m.failures = msg.Failures
+		m.totalDirs = msg.TotalDirs
+		return m, nil
+
+	case ProgressMsg:
+		m.currentDirName = msg.CurrentDir
+		m.currentDir = msg.CurrentDirNum
+		m.totalDirs = msg.TotalDirs
+		m.processedFiles = msg.ProcessedFiles
+		m.totalFiles = msg.TotalFiles
+		m.processingMsg = msg.Message
+		return m, nil
+
+	case FileProcessedMsg:
+		logEntry := fmt.Sprintf("✓ %s (%s) → %s", msg.FileName, msg.FileType, msg.ConvertedTo)
+		m.conversionLog = append(m.conversionLog, logEntry)
+		// Keep only last 10 log entries to avoid cluttering
+		if len(m.conversionLog) > 10 {
+			m.conversionLog = m.conversionLog[len(m.conversionLog)-10:]
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch m.state {
+		case StateSelectDirectory:
+			return m.updateDirectorySelection(msg)
+		case StateSelectItems:
+			return m.updateItemSelection(msg)
+		case StateSelectFormat:
+			return m.updateFormatSelection(msg)
+		case StateConfirm:
+			return m.updateConfirm(msg)
+		case StateProcessing:
+			return m.updateProcessing(msg)
+		case StateComplete, StateError:
+			return m.updateComplete(msg)
+		}
+	}
+
+	return m, nil
+}
+
+// View implements the tea.Model interface
+func (m Model) View() string {
+	switch m.state {
+	case StateSelectDirectory:
+		return m.viewDirectorySelection()
+	case StateSelectItems:
+		return m.viewItemSelection()
+	case StateSelectFormat:
+		return m.viewFormatSelection()
+	case StateConfirm:
+		return m.viewConfirm()
+	case StateProcessing:
+		return m.viewProcessing()
+	case StateComplete:
+		return m.viewComplete()
+	case StateError:
+		return m.viewError()
+	default:
+		return "Unknown state"
+	}
+}
+
+// updateDirectorySelection handles input during directory selection
+func (m Model) updateDirectorySelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "enter":
+		if m.directoryPath != "" {
+			// Validate directory exists
+			if _, err := os.Stat(m.directoryPath); os.IsNotExist(err) {
+				m.state = StateError
+				m.errorMsg = fmt.Sprintf("Directory does not exist: %s", m.directoryPath)
+				return m, nil
+			}
+
+			// Determine operation mode
+			if err := m.determineOperationMode(); err != nil {
+				m.state = StateError
+				m.errorMsg = err.Error()
+				return m, nil
+			}
+
+			m.state = StateSelectItems
+			m.cursor = 0 // Reset cursor for item selection
+		}
+	case "backspace":
+		if len(m.directoryPath) > 0 {
+			m.directoryPath = m.directoryPath[:len(m.directoryPath)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			m.directoryPath += msg.String()
+		}
+	}
+	return m, nil
+}
+
+// updateFormatSelection handles input during format selection
+func (m Model) updateFormatSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.editingOutput || m.editingTrash {
+		return m.updateOutputInput(msg)
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.formats)-1 {
+			m.cursor++
+		}
+	case "left", "h":
+		m.presetIndex = (m.presetIndex + len(fileops.Presets) - 1) % len(fileops.Presets)
+		m.applyPreset()
+	case "right", "l":
+		m.presetIndex = (m.presetIndex + 1) % len(fileops.Presets)
+		m.applyPreset()
+	case "s":
+		m.imageOptions.SplitSpreads = !m.imageOptions.SplitSpreads
+	case "m":
+		m.imageOptions.RightToLeft = !m.imageOptions.RightToLeft
+	case "e":
+		m.imageOptions.OutputFormat = nextEncoder(m.imageOptions.OutputFormat)
+	case "p":
+		m.renumberPages = !m.renumberPages
+	case "f":
+		m.imagesOnly = !m.imagesOnly
+	case "i":
+		m.comicInfo = (m.comicInfo + 1) % (archive.ComicInfoOff + 1)
+	case "t":
+		m.nameTemplate = (m.nameTemplate + 1) % len(archive.NameTemplates)
+	case "o":
+		m.collision = (m.collision + 1) % (archive.CollisionSkip + 1)
+	case "d":
+		m.editingOutput = true
+	case "w":
+		m.editingTrash = true
+	case "c":
+		if m.imageOptions.ColorProfile == fileops.ColorProfileSRGB {
+			m.imageOptions.ColorProfile = fileops.ColorProfileEmbed
+		} else {
+			m.imageOptions.ColorProfile = fileops.ColorProfileSRGB
+		}
+	case "x":
+		if m.imageOptions.Metadata == fileops.MetadataStrip {
+			m.imageOptions.Metadata = fileops.MetadataKeep
+		} else {
+			m.imageOptions.Metadata = fileops.MetadataStrip
+		}
+	case "enter":
+		// Make sure the image encoder and archive tool can run before
+		// starting
+		encoder, err := fileops.FindEncoder(m.imageOptions.OutputFormat)
+		if err == nil {
+			err = encoder.Available()
+		}
+		if err == nil {
+			format := strings.ToLower(strings.Split(m.formats[m.cursor], " ")[0])
+			err = archive.CheckArchiveTool(archive.ArchiveType(format))
+		}
+		if err != nil {
+			m.state = StateError
+			m.errorMsg = err.Error()
+			return m, nil
+		}
+
+		m.selectedFormat = m.formats[m.cursor]
+		m.plans = nil
+		m.planGeneration++
+		m.state = StateConfirm
+		return m, m.planArchives()
+	case "tab":
+		m.deleteOriginal = !m.deleteOriginal
+	}
+	return m, nil
+}
+
+// updateOutputInput handles typing the output or trash directory on the
+// format screen
+func (m Model) updateOutputInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	text := &m.outputRoot
+	if m.editingTrash {
+		text = &m.trashDir
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "enter", "esc":
+		*text = strings.TrimSpace(*text)
+		m.editingOutput, m.editingTrash = false, false
+	case "backspace":
+		if len(*text) > 0 {
+			*text = (*text)[:len(*text)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			*text += msg.String()
+		}
+	}
+	return m, nil
+}
+
+// nextEncoder returns the name of the image encoder after current
+func nextEncoder(current string) string {
+	names := fileops.EncoderNames()
+	for i, name := range names {
+		if strings.EqualFold(name, current) {
+			return names[(i+1)%len(names)]
+		}
+	}
+	// An empty name means the default, the first encoder
+	return names[1%len(names)]
+}
+
+// archiveOptions returns the archive options for the current settings
+func (m Model) archiveOptions() archive.Options {
+	return archive.Options{
+		Image:         m.imageOptions,
+		RenumberPages: m.renumberPages,
+		ComicInfo:     m.comicInfo,
+		Verify:        m.deleteOriginal,
+		Filter: archive.Filter{
+			ImagesOnly:    m.imagesOnly,
+			IncludeHidden: m.includeHidden,
+			Symlinks:      m.symlinks,
+		},
+	}
+}
+
+// applyPreset loads the image options of the current preset and moves the
+// format cursor to its archive format
+func (m *Model) applyPreset() {
+	preset := fileops.Presets[m.presetIndex]
+	// Color profile and metadata handling is not device specific, so it
+	// survives preset changes
+	colorProfile, metadata := m.imageOptions.ColorProfile, m.imageOptions.Metadata
+	m.imageOptions = preset.Options
+	m.imageOptions.ColorProfile, m.imageOptions.Metadata = colorProfile, metadata
+	for i, format := range m.formats {
+		if strings.ToLower(strings.Split(format, " ")[0]) == preset.Format {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+// updateConfirm handles input on the confirmation screen
+func (m Model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "b":
+		// Drop the plan still being worked out, it is for the settings
+		// being left
+		m.planGeneration++
+		m.plans = nil
+		m.state = StateSelectFormat
+	case "enter", "y":
+		if m.plans == nil {
+			return m, nil
+		}
+		namer, err := archive.NewNamer(archive.NameTemplates[m.nameTemplate], m.collision)
+		if err != nil {
+			m.state = StateError
+			m.errorMsg = err.Error()
+			return m, nil
+		}
+		m.namer = namer
+		if m.deleteOriginal {
+			if m.trash, err = trash.New(m.trashDir); err != nil {
+				m.state = StateError
+				m.errorMsg = err.Error()
+				return m, nil
+			}
+		}
+		m.state = StateProcessing
+		return m, m.startProcessing()
+	}
+	return m, nil
+}
+
+// updateProcessing handles input during processing
+func (m Model) updateProcessing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// updateComplete handles input when processing is complete
+func (m Model) updateComplete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "enter":
+		return m, tea.Quit
+	case "r":
+		// Restart the application
+		return InitialModel(), nil
+	}
+	return m, nil
+}
+
+// startProcessing begins the directory processing
+func (m Model) startProcessing() tea.Cmd {
+	// First, count directories and set up initial progress
+	return m.countDirectories()
+}
+
+// processItems lists the items to archive. Loose files stored in their own
+// archive are processed together, as the chosen directory; the selected
+// directories follow in the order they are listed.
+func (m Model) processItems() []string {
+	var items []string
+	if len(m.looseFiles(archive.LooseArchive)) > 0 {
+		items = append(items, m.directoryPath)
+	}
+	for _, item := range m.availableItems {
+		if m.selectedItems[item] && !m.fileItems[item] {
+			items = append(items, filepath.Join(m.directoryPath, filepath.FromSlash(item)))
+		}
+	}
+	return items
+}
+
+// itemSource returns the source directory of an item from processItems, the
+// directory its archive goes next to and the archive options. The loose
+// files of the chosen directory have no source directory: they are attached
+// to an archive named after it, inside it. Directories get the loose files
+// attached to every directory.
+func (m Model) itemSource(itemPath string) (string, string, archive.Options) {
+	opts := m.archiveOptions()
+	if itemPath == m.directoryPath {
+		opts.Name = filepath.Base(m.directoryPath)
+		opts.Attach = m.looseFiles(archive.LooseArchive)
+		return "", m.directoryPath, opts
+	}
+	opts.Attach = m.looseFiles(archive.LooseAttach)
+	return itemPath, filepath.Dir(itemPath), opts
+}
+
+// pageMappingPath returns where the page mapping of an archive is written:
+// "Book.pages.txt" next to "Book.cbz"
+func pageMappingPath(archivePath string) string {
+	return strings.TrimSuffix(archivePath, filepath.Ext(archivePath)) + ".pages.txt"
+}
+
+// writePageMapping writes which archive entry each source file became next
+// to the archive, in the format of the CLI -report option
+func writePageMapping(report *archive.Report) error {
+	file, err := os.Create(pageMappingPath(report.Archive))
+	if err != nil {
+		return fmt.Errorf("failed to write the page mapping: %v", err)
+	}
+	if err := report.WriteMapping(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write the page mapping: %v", err)
+	}
+	return file.Close()
+}
+
+// planArchives works out the archive each item would be written to and what
+// would go in it, without writing anything. The plans are tagged with the
+// current plan generation.
+func (m Model) planArchives() tea.Cmd {
+	generation := m.planGeneration
+	return func() tea.Msg {
+		format := strings.ToLower(strings.Split(m.selectedFormat, " ")[0])
+		namer, err := archive.NewNamer(archive.NameTemplates[m.nameTemplate], m.collision)
+
+		plans := []itemPlan{}
+		for _, itemPath := range m.processItems() {
+			p := itemPlan{name: filepath.Base(itemPath), err: err}
+			source, parentDir, opts := m.itemSource(itemPath)
+			var outDir, archivePath string
+			if p.err == nil {
+				outDir, p.err = archive.MirrorDir(m.directoryPath, m.outputRoot, parentDir)
+			}
+			if p.err == nil {
+				archivePath, p.err = namer.Path(outDir, filepath.Base(itemPath), format)
+			}
+			if p.err == nil {
+				p.plan, p.err = archive.PlanArchive(source, opts, archive.PlanSamples)
+			}
+			if p.err == nil {
+				p.plan.Archive = archivePath
+				_, statErr := os.Stat(archivePath)
+				p.replaces = statErr == nil
+			}
+			plans = append(plans, p)
+		}
+		return PlanMsg{Plans: plans, Generation: generation}
+	}
+}
+
+// itemPlan is the plan for one item on the confirmation screen
+type itemPlan struct {
+	name     string
+	plan     *archive.Plan
+	replaces bool  // The archive exists and would be overwritten
+	err      error // Why the item would not be archived
+}
+
+// PlanMsg carries the plans for the confirmation screen
+type PlanMsg struct {
+	Plans      []itemPlan
+	Generation int // Plan generation of the request, see Model.planGeneration
+}
+
+// countDirectories counts the total directories or files to process
+func (m Model) countDirectories() tea.Cmd {
+	return func() tea.Msg {
+		items := m.processItems()
+		return DirectoryCountMsg{
+			TotalDirs:   len(items),
+			Directories: items,
+		}
+	}
+}
+
+// DirectoryCountMsg is sent when directory counting is complete
+type DirectoryCountMsg struct {
+	TotalDirs   int
+	Directories []string
+}
+
+// processDirectories processes directories with progress updates
+func (m Model) processDirectories(directories []string) tea.Cmd {
+	return func() tea.Msg {
+		// Start processing the first directory
+		return ProcessDirectoryMsg{
+			Directories:   directories,
+			CurrentIndex:  0,
+			CompletedDirs: []string{},
+		}
+	}
+}
+
+// ProcessDirectoryMsg is sent to process the next directory
+type ProcessDirectoryMsg struct {
+	Directories   []string
+	CurrentIndex  int
+	CompletedDirs []string
+	Failures      []string
+}
+
+// ProcessingCompleteMsg is sent when processing is complete
+type ProcessingCompleteMsg struct {
+	CompletedDirs []string
+	Failures      []string
+	TotalDirs     int
+}
+
+// ProgressMsg is sent during processing to update progress
+type ProgressMsg struct {
+	CurrentDir     string
+	CurrentDirNum  int
+	TotalDirs      int
+	ProcessedFiles int
+	TotalFiles     int
+	Message        string
+}
+
+// FileProcessedMsg is sent when a file is processed
+type FileProcessedMsg struct {
+	FileName    string
+	FileType    string
+	ConvertedTo string
+}
+
+// viewDirectorySelection renders the directory selection screen
+func (m Model) viewDirectorySelection() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		Render("📁 CBZ WebP Converter")
+
+	instruction := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Enter the directory path to process:")
+
+	input := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("238")).
+		Padding(0, 1).
+		Render(m.directoryPath + "█")
+
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Press Enter to continue, Ctrl+C or 'q' to quit")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			instruction,
+			"",
+			input,
+			"",
+			help,
+		),
+	)
+}
+
+// viewFormatSelection renders the format selection screen
+func (m Model) viewFormatSelection() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		Render("📁 CBZ WebP Converter")
+
+	directory := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("Directory: %s", m.directoryPath))
+
+	instruction := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Select archive format:")
+
+	var formatOptions []string
+	for i, format := range m.formats {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		formatOptions = append(formatOptions, fmt.Sprintf("%s %s", cursor, format))
+	}
+
+	formats := lipgloss.JoinVertical(lipgloss.Left, formatOptions...)
+
+	preset := fileops.Presets[m.presetIndex]
+	presetText := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("220")).
+		Render(fmt.Sprintf("Device preset: ◀ %s ▶  %s", preset.Name, preset.Description))
+
+	deleteOption := " "
+	if m.deleteOriginal {
+		deleteOption = "✓"
+	}
+	deleteText := lipgloss.NewStyle().
+		Render(fmt.Sprintf("%s Move original files to the trash after conversion", deleteOption))
+
+	splitOption := " "
+	if m.imageOptions.SplitSpreads {
+		splitOption = "✓"
+	}
+	rtlOption := " "
+	if m.imageOptions.RightToLeft {
+		rtlOption = "✓"
+	}
+	encoderName := fileops.EncoderNames()[0]
+	if m.imageOptions.OutputFormat != "" {
+		encoderName = m.imageOptions.OutputFormat
+	}
+	if encoderName == fileops.EncoderNames()[0] && fileops.WebPLossless {
+		encoderName += " (lossless)"
+	}
+	profileName := "convert to sRGB"
+	if m.imageOptions.ColorProfile == fileops.ColorProfileEmbed {
+		profileName = "embed"
+	}
+	encoderText := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("220")).
+		Render(fmt.Sprintf("Image format: %s   Color profiles: %s   Metadata: %s", encoderName, profileName, m.imageOptions.Metadata))
+
+	spreadText := lipgloss.NewStyle().
+		Render(fmt.Sprintf("%s Split double-page spreads   %s Right-to-left (manga)", splitOption, rtlOption))
+
+	renumberOption := " "
+	if m.renumberPages {
+		renumberOption = "✓"
+	}
+	imagesOnlyOption := " "
+	if m.imagesOnly {
+		imagesOnlyOption = "✓"
+	}
+	renumberText := lipgloss.NewStyle().
+		Render(fmt.Sprintf("%s Renumber pages (0001.webp, 0002.webp..., listed in a .pages.txt file)   %s Images only   ComicInfo.xml: %s", renumberOption, imagesOnlyOption, m.comicInfo))
+
+	// Show the archive name the template gives for the first selected item
+	format := strings.ToLower(strings.Split(m.formats[m.cursor], " ")[0])
+	exampleName := filepath.Base(m.directoryPath)
+	if m.operationMode != ModeFiles {
+		for _, item := range m.availableItems {
+			if m.selectedItems[item] && !m.fileItems[item] {
+				exampleName = path.Base(item)
+				break
+			}
+		}
+	}
+	example := archive.NameTemplates[m.nameTemplate]
+	if template, err := archive.ParseNameTemplate(example); err == nil {
+		example = template.Render(exampleName, format)
+	}
+	nameText := lipgloss.NewStyle().
+		Render(fmt.Sprintf("Archive names: %s → %s   Existing archives: %s", archive.NameTemplates[m.nameTemplate], example, m.collision))
+
+	outputDir := m.outputRoot
+	if m.editingOutput {
+		outputDir += "█"
+	} else if outputDir == "" {
+		outputDir = "next to the sources"
+	}
+	trashDir := m.trashDir
+	if m.editingTrash {
+		trashDir += "█"
+	} else if trashDir == "" {
+		trashDir = "default"
+		if bin, err := trash.Default(); err == nil {
+			trashDir += " (" + bin.Dir + ")"
+		}
+	}
+	outputText := lipgloss.NewStyle().
+		Render(fmt.Sprintf("Output directory: %s   Trash: %s", outputDir, trashDir))
+
+	helpText := "Use ↑/↓ to navigate, ←/→ to change preset, Tab to toggle delete option, e to change image format, c/x to change color profile and metadata handling, s/m to toggle spread options, p/f to toggle page renumbering and images only, i to change ComicInfo.xml handling, t/o to change archive naming and existing archive handling, d/w to set the output and trash directories, Enter to review and start, Ctrl+C or 'q' to quit"
+	if m.editingOutput {
+		helpText = "Type the output directory, empty for next to the sources, Enter to confirm"
+	} else if m.editingTrash {
+		helpText = "Type the directory originals are moved to, empty for the default trash, Enter to confirm"
+	}
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(helpText)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			directory,
+			"",
+			instruction,
+			"",
+			formats,
+			"",
+			presetText,
+			encoderText,
+			"",
+			deleteText,
+			spreadText,
+			renumberText,
+			nameText,
+			outputText,
+			"",
+			help,
+		),
+	)
+}
+
+// viewProcessing renders the processing screen
+func (m Model) viewProcessing() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		Render("🔄 Processing...")
+
+	// Overall progress
+	overallProgress := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("Directories: %d/%d", m.currentDir, m.totalDirs))
+
+	// Current directory info
+	currentDirInfo := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("220")).
+		Render(fmt.Sprintf("Current: %s", m.currentDirName))
+
+	// Progress bar
+	progressBar := m.renderProgressBar()
+
+	// Status message
+	statusMsg := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(m.processingMsg)
+
+	// Help text
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Press Ctrl+C or 'q' to quit")
+
+	content := lipgloss.JoinVertical(lipgloss.Center,
+		title,
+		"",
+		overallProgress,
+		currentDirInfo,
+		"",
+		progressBar,
+		"",
+		statusMsg,
+		"",
+		help,
+	)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+}
+
+// renderProgressBar creates a visual progress bar
+func (m Model) renderProgressBar() string {
+	if m.totalDirs == 0 {
+		return ""
+	}
+
+	progress := float64(m.currentDir) / float64(m.totalDirs)
+	barWidth := 30
+	filledWidth := int(progress * float64(barWidth))
+
+	bar := "["
+	for i := 0; i < barWidth; i++ {
+		if i < filledWidth {
+			bar += "█"
+		} else {
+			bar += "░"
+		}
+	}
+	bar += "]"
+
+	percentage := int(progress * 100)
+
+	return lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Render(fmt.Sprintf("%s %d%%", bar, percentage))
+}
+
+// viewComplete renders the completion screen
+func (m Model) viewComplete() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("46")).
+		Render("✅ Processing Complete!")
+
+	summary := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("Successfully processed %d directories", len(m.completedDirs)))
+	if m.renumberPages && len(m.completedDirs) > 0 {
+		summary += "\n" + lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240")).
+			Render("The source file of each renumbered page is listed next to its archive in a .pages.txt file")
+	}
+
+	// List what went wrong, as the remaining items were still processed
+	var failureLines []string
+	for _, failure := range m.failures {
+		failureLines = append(failureLines, "✗ "+failure)
+	}
+	failures := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Render(strings.Join(failureLines, "\n"))
+
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Press Enter or 'q' to quit, 'r' to restart")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			summary,
+			failures,
+			"",
+			help,
+		),
+	)
+}
+
+// viewConfirm renders the confirmation screen, listing the archives that
+// would be created
+func (m Model) viewConfirm() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		Render("📋 Confirm")
+
+	if m.plans == nil {
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+			lipgloss.JoinVertical(lipgloss.Center,
+				title,
+				"",
+				lipgloss.NewStyle().
+					Foreground(lipgloss.Color("240")).
+					Render("Sampling pages to estimate archive sizes..."),
+			),
+		)
+	}
+
+	var lines []string
+	var archives, images int
+	var inputSize, estimatedSize int64
+	for _, p := range m.plans {
+		if p.err != nil {
+			lines = append(lines, lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196")).
+				Render(fmt.Sprintf("✗ %s: %v", p.name, p.err)))
+			continue
+		}
+		archives++
+		images += len(p.plan.Images)
+		inputSize += p.plan.InputSize
+		estimatedSize += p.plan.EstimatedSize
+
+		action := ""
+		if p.replaces {
+			action = " (replaces the existing archive)"
+		}
+		lines = append(lines, lipgloss.NewStyle().
+			Foreground(lipgloss.Color("220")).
+			Render(fmt.Sprintf("%s → %s%s", p.name, p.plan.Archive, action)))
+		for _, line := range p.plan.Summary() {
+			lines = append(lines, lipgloss.NewStyle().
+				Foreground(lipgloss.Color("240")).
+				Render("  "+line))
+		}
+	}
+
+	// Keep the list on screen, leaving room for the title, totals and help
+	maxLines := m.height - 10
+	if maxLines < 5 {
+		maxLines = 5
+	}
+	if len(lines) > maxLines {
+		more := len(lines) - maxLines + 1
+		lines = append(lines[:maxLines-1], fmt.Sprintf("... %d more lines", more))
+	}
+	list := lipgloss.NewStyle().
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	totalText := fmt.Sprintf("%d archives, %d images, %s → about %s", archives, images, archive.FormatSize(inputSize), archive.FormatSize(estimatedSize))
+	if m.deleteOriginal {
+		totalText += " • originals will be moved to the trash"
+	}
+	total := lipgloss.NewStyle().
+		Bold(true).
+		Render(totalText)
+
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Press Enter or 'y' to start, Esc or 'b' to go back, Ctrl+C or 'q' to quit")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			list,
+			"",
+			total,
+			"",
+			help,
+		),
+	)
+}
+
+// loadBookTree lists the book directories anywhere below the chosen
+// directory for recursive mode, together with the directories leading to
+// them, so they can be shown as a tree
+func (m *Model) loadBookTree() error {
+	books, err := archive.FindBookDirectories(m.directoryPath, m.archiveOptions().Filter)
+	if err != nil {
+		return err
+	}
+
+	m.bookDirs = make(map[string]bool)
+	m.fileItems = make(map[string]bool)
+	seen := make(map[string]bool)
+	m.availableItems = nil
+	for _, book := range books {
+		if book == "." {
+			continue
+		}
+		m.bookDirs[book] = true
+		for dir := book; dir != "." && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			m.availableItems = append(m.availableItems, dir)
+		}
+	}
+	if len(m.availableItems) == 0 {
+		return fmt.Errorf("no directories with images found below %s", m.directoryPath)
+	}
+
+	sort.SliceStable(m.availableItems, func(i, j int) bool {
+		return fileops.NaturalLess(m.availableItems[i], m.availableItems[j])
+	})
+	return nil
+}
+
+// looseFiles returns the paths of the selected files stored in the given
+// way. In file mode every selected file goes into one archive.
+func (m Model) looseFiles(mode archive.LooseFileMode) []string {
+	var files []string
+	for _, item := range m.availableItems {
+		if !m.fileItems[item] || !m.selectedItems[item] {
+			continue
+		}
+		if m.looseModes[item] == mode || (m.operationMode == ModeFiles && mode == archive.LooseArchive) {
+			files = append(files, filepath.Join(m.directoryPath, item))
+		}
+	}
+	return files
+}
+
+// branchBooks returns the book directories at or below item in recursive mode
+func (m Model) branchBooks(item string) []string {
+	var books []string
+	for _, other := range m.availableItems {
+		if m.bookDirs[other] && (other == item || strings.HasPrefix(other, item+"/")) {
+			books = append(books, other)
+		}
+	}
+	return books
+}
+
+// updateItemSelection handles input during item selection
+func (m Model) updateItemSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+			// Adjust start index if cursor moves above visible area
+			if m.cursor < m.itemStartIndex {
+				m.itemStartIndex = m.cursor
+			}
+		}
+	case "down", "j":
+		if m.cursor < len(m.availableItems)-1 {
+			m.cursor++
+			// Adjust start index if cursor moves below visible area
+			if m.cursor >= m.itemStartIndex+m.itemsPerPage {
+				m.itemStartIndex = m.cursor - m.itemsPerPage + 1
+			}
+		}
+	case "space", " ":
+		// Toggle selection of current item
+		if m.operationMode == ModeRecursive && m.cursor < len(m.availableItems) {
+			// Toggle every book in the branch: select all unless all are selected
+			books := m.branchBooks(m.availableItems[m.cursor])
+			selectAll := false
+			for _, book := range books {
+				if !m.selectedItems[book] {
+					selectAll = true
+				}
+			}
+			for _, book := range books {
+				if selectAll {
+					m.selectedItems[book] = true
+				} else {
+					delete(m.selectedItems, book)
+				}
+			}
+		} else if m.operationMode == ModeMixed && m.cursor < len(m.availableItems) && m.fileItems[m.availableItems[m.cursor]] {
+			// Loose files cycle through their own archive, attached to
+			// every directory and ignored
+			item := m.availableItems[m.cursor]
+			switch {
+			case !m.selectedItems[item]:
+				m.selectedItems[item] = true
+				m.looseModes[item] = archive.LooseArchive
+			case m.looseModes[item] == archive.LooseArchive:
+				m.looseModes[item] = archive.LooseAttach
+			default:
+				delete(m.selectedItems, item)
+				m.looseModes[item] = archive.LooseIgnore
+			}
+		} else if m.cursor >= 0 && m.cursor < len(m.availableItems) {
+			currentItem := m.availableItems[m.cursor]
+			if m.selectedItems[currentItem] {
+				delete(m.selectedItems, currentItem)
+			} else {
+				m.selectedItems[currentItem] = true
+			}
+		}
+	case "enter":
+		// Only proceed if at least one item is selected
+		if len(m.selectedItems) > 0 {
+			m.state = StateSelectFormat
+			m.cursor = 0 // Reset cursor for format selection
+		}
+	case "a":
+		// Select all items
+		for _, item := range m.availableItems {
+			if m.operationMode != ModeRecursive || m.bookDirs[item] {
+				m.selectedItems[item] = true
+			}
+			if m.fileItems[item] && m.looseModes[item] == archive.LooseIgnore {
+				m.looseModes[item] = archive.LooseArchive
+			}
+		}
+	case ".", "s":
+		// Show or hide hidden files, follow or skip symlinks, and list the
+		// items again
+		if msg.String() == "." {
+			m.includeHidden = !m.includeHidden
+		} else {
+			m.symlinks = (m.symlinks + 1) % (archive.SymlinkSkip + 1)
+		}
+		var err error
+		if m.operationMode == ModeRecursive {
+			err = m.loadBookTree()
+		} else {
+			err = m.determineOperationMode()
+		}
+		if err != nil {
+			m.state = StateError
+			m.errorMsg = err.Error()
+			return m, nil
+		}
+		m.selectedItems = make(map[string]bool)
+		m.cursor = 0
+		m.itemStartIndex = 0
+	case "r":
+		// Switch between the subdirectories and the book directories
+		// anywhere below them
+		var err error
+		switch m.operationMode {
+		case ModeDirectories, ModeMixed:
+			m.operationMode = ModeRecursive
+			err = m.loadBookTree()
+		case ModeRecursive:
+			err = m.determineOperationMode()
+		default:
+			return m, nil
+		}
+		if err != nil {
+			m.state = StateError
+			m.errorMsg = err.Error()
+			return m, nil
+		}
+		m.selectedItems = make(map[string]bool)
+		m.cursor = 0
+		m.itemStartIndex = 0
+	case "n":
+		// Deselect all items
+		m.selectedItems = make(map[string]bool)
+	}
+	return m, nil
+}
+
+// viewItemSelection renders the item selection screen
+func (m Model) viewItemSelection() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		Render("📁 CBZ WebP Converter")
+
+	modeText := "Select directories to archive"
+	if m.operationMode == ModeFiles {
+		modeText = "Select files to include in archive"
+	} else if m.operationMode == ModeRecursive {
+		modeText = "Select book directories to archive, or a branch to select every book in it"
+	} else if m.operationMode == ModeMixed {
+		modeText = "Select directories to archive, and choose how the loose files next to them are stored"
+	}
+
+	instruction := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(modeText)
+
+	// Calculate visible items
+	endIndex := m.itemStartIndex + m.itemsPerPage
+	if endIndex > len(m.availableItems) {
+		endIndex = len(m.availableItems)
+	}
+	visibleItems := m.availableItems[m.itemStartIndex:endIndex]
+
+	// Build the list of items
+	var itemList []string
+	for i, item := range visibleItems {
+		cursor := " "
+		if m.itemStartIndex+i == m.cursor {
+			cursor = ">"
+		}
+
+		checkbox := "[ ]"
+		if m.selectedItems[item] {
+			checkbox = "[✓]"
+		}
+
+		name := item
+		if m.operationMode == ModeRecursive {
+			// Indent by depth; branches show whether all, some or none of
+			// their books are selected
+			name = strings.Repeat("  ", strings.Count(item, "/")) + path.Base(item)
+			if !m.bookDirs[item] {
+				name += "/"
+				books, selected := m.branchBooks(item), 0
+				for _, book := range books {
+					if m.selectedItems[book] {
+						selected++
+					}
+				}
+				if selected == len(books) {
+					checkbox = "[✓]"
+				} else if selected > 0 {
+					checkbox = "[~]"
+				}
+			}
+		}
+
+		if m.operationMode == ModeMixed && !m.fileItems[item] {
+			name += "/"
+		} else if m.operationMode == ModeMixed && m.selectedItems[item] {
+			if m.looseModes[item] == archive.LooseAttach {
+				name += "  (attach to each directory)"
+			} else {
+				name += "  (own archive)"
+			}
+		}
+
+		itemText := fmt.Sprintf("%s %s %s", cursor, checkbox, name)
+		if m.itemStartIndex+i == m.cursor {
+			itemText = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("205")).
+				Render(itemText)
+		}
+		itemList = append(itemList, itemText)
+	}
+
+	items := lipgloss.JoinVertical(lipgloss.Left, itemList...)
+
+	// Show scrollbar if needed
+	if len(m.availableItems) > m.itemsPerPage {
+		scrollPosition := fmt.Sprintf("(%d/%d)", m.cursor+1, len(m.availableItems))
+		items = lipgloss.JoinHorizontal(lipgloss.Top, items, "  ", scrollPosition)
+	}
+
+	total := len(m.availableItems)
+	if m.operationMode == ModeRecursive {
+		total = len(m.bookDirs)
+	}
+	selectedCount := fmt.Sprintf("Selected: %d/%d", len(m.selectedItems), total)
+
+	hiddenText := "skipped"
+	if m.includeHidden {
+		hiddenText = "included"
+	}
+	walkText := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("Hidden files: %s • Symlinks: %s", hiddenText, m.symlinks))
+
+	// Preview how the archive name is read for ComicInfo.xml: each directory
+	// becomes its own book, files are combined into one named after the
+	// parent directory
+	bookName := filepath.Base(m.directoryPath)
+	if m.operationMode != ModeFiles && m.cursor < len(m.availableItems) && !m.fileItems[m.availableItems[m.cursor]] {
+		bookName = path.Base(m.availableItems[m.cursor])
+	}
+	parsed := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("220")).
+		Render(archive.ParseBookName(bookName).String())
+
+	helpText := "↑/↓: Navigate • Space: Toggle • a: Select All • n: None • .: Hidden • s: Symlinks • Enter: Continue • Ctrl+c/q: Quit"
+	if m.operationMode == ModeDirectories {
+		helpText = "↑/↓: Navigate • Space: Toggle • a: Select All • n: None • r: Recursive • .: Hidden • s: Symlinks • Enter: Continue • Ctrl+c/q: Quit"
+	} else if m.operationMode == ModeMixed {
+		helpText = "↑/↓: Navigate • Space: Toggle, or own archive/attach/ignore for files • a: Select All • n: None • r: Recursive • .: Hidden • s: Symlinks • Enter: Continue • Ctrl+c/q: Quit"
+	} else if m.operationMode == ModeRecursive {
+		helpText = "↑/↓: Navigate • Space: Toggle • a: Select All • n: None • r: Top Level Only • .: Hidden • s: Symlinks • Enter: Continue • Ctrl+c/q: Quit"
+	}
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(helpText)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			instruction,
+			"",
+			items,
+			"",
+			parsed,
+			"",
+			selectedCount,
+			walkText,
+			"",
+			help,
+		),
+	)
+}
+
+// viewError renders the error screen
+func (m Model) viewError() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		Render("❌ Error")
+
+	error := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render(m.errorMsg)
+
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Render("Press Enter or 'q' to quit, 'r' to restart")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"",
+			error,
+			"",
+			help,
+		),
+	)
+}
@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStalePlansDropped(t *testing.T) {
+	m := InitialModel()
+	m.state = StateConfirm
+	m.planGeneration = 1
+	first := PlanMsg{Plans: []itemPlan{{name: "first"}}, Generation: 1}
+
+	// Going back and confirming again asks for a new plan
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(Model)
+	m.planGeneration++
+	m.state = StateConfirm
+	second := PlanMsg{Plans: []itemPlan{{name: "second"}}, Generation: m.planGeneration}
+
+	tests := []struct {
+		name string
+		msgs []PlanMsg
+		want string // Name of the shown plan, empty for none
+	}{
+		{"stale only", []PlanMsg{first}, ""},
+		{"current", []PlanMsg{second}, "second"},
+		{"stale after current", []PlanMsg{second, first}, "second"},
+		{"stale before current", []PlanMsg{first, second}, "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := m
+			for _, msg := range tt.msgs {
+				updated, _ := model.Update(msg)
+				model = updated.(Model)
+			}
+			got := ""
+			if len(model.plans) > 0 {
+				got = model.plans[0].name
+			}
+			if got != tt.want {
+				t.Errorf("shown plan %q, want %q", got, tt.want)
+			}
+		})
+	}
+}